@@ -2,24 +2,34 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/BohdanMelnyk/bus-shulter-checker/notification"
 	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/BohdanMelnyk/bus-shulter-checker/tracker"
 	"github.com/joho/godotenv"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type CheckResult struct {
-	Name           string    `json:"name"`
-	URL            string    `json:"url"`
-	Available      bool      `json:"available"`
-	CheckedDates   []string `json:"checkedDates"`
-	AvailableDates []string `json:"availableDates"`
-	CheckedAt      time.Time `json:"checkedAt"`
+	WatchID         string               `json:"watchId"`
+	Name            string               `json:"name"`
+	URL             string               `json:"url"`
+	Available       bool                 `json:"available"`
+	CheckedDates    []string             `json:"checkedDates"`
+	AvailableDates  []string             `json:"availableDates"`
+	Dates           []shuttle.DateStatus `json:"dates,omitempty"`
+	CheckedAt       time.Time            `json:"checkedAt"`
+	Cached          bool                 `json:"cached"`
+	CacheAgeSeconds int                  `json:"cacheAgeSeconds,omitempty"`
+	Error           string               `json:"error,omitempty"`
+	ErrorKind       string               `json:"errorKind,omitempty"`
 }
 
 type AllChecksResponse struct {
@@ -46,26 +56,37 @@ func main() {
 		log.Println("Error loading .env file:", err)
 	}
 
+	// "outbox" inspects undelivered notifications instead of running checks
+	if len(os.Args) > 1 && os.Args[1] == "outbox" {
+		if err := outboxCommand(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Get configuration from environment variables
 	mailgunDomain := os.Getenv("MAILGUN_DOMAIN")
 	mailgunAPIKey := os.Getenv("MAILGUN_API_KEY")
 	recipientEmail := os.Getenv("RECIPIENT_EMAIL")
 	senderEmail := os.Getenv("SENDER_EMAIL")
 
-	// Validate required environment variables
+	// Mailgun is optional, but a half-configured account is a mistake
+	mailgunConfigured := mailgunDomain != "" || mailgunAPIKey != ""
 	var missingVars []string
 
-	if mailgunDomain == "" {
-		missingVars = append(missingVars, "MAILGUN_DOMAIN")
-	}
-	if mailgunAPIKey == "" {
-		missingVars = append(missingVars, "MAILGUN_API_KEY")
-	}
-	if recipientEmail == "" {
-		missingVars = append(missingVars, "RECIPIENT_EMAIL")
-	}
-	if senderEmail == "" {
-		missingVars = append(missingVars, "SENDER_EMAIL")
+	if mailgunConfigured {
+		if mailgunDomain == "" {
+			missingVars = append(missingVars, "MAILGUN_DOMAIN")
+		}
+		if mailgunAPIKey == "" {
+			missingVars = append(missingVars, "MAILGUN_API_KEY")
+		}
+		if recipientEmail == "" {
+			missingVars = append(missingVars, "RECIPIENT_EMAIL")
+		}
+		if senderEmail == "" {
+			missingVars = append(missingVars, "SENDER_EMAIL")
+		}
 	}
 
 	if len(missingVars) > 0 {
@@ -73,15 +94,367 @@ func main() {
 	}
 
 	// Create an API client
-	apiClient := shuttle.NewAPIClient()
+	var clientOpts []shuttle.Option
+	if os.Getenv("SHUTTLE_WARMUP") == "true" {
+		clientOpts = append(clientOpts, shuttle.WithWarmUp())
+	}
+	clientOpts = append(clientOpts, shuttle.WithHeaderProfile(shuttle.HeaderProfile{
+		UserAgent:   os.Getenv("SHUTTLE_USER_AGENT"),
+		AppLanguage: os.Getenv("SHUTTLE_APP_LANGUAGE"),
+		AppVersion:  os.Getenv("SHUTTLE_APP_VERSION"),
+	}))
+	if os.Getenv("SHUTTLE_DISCOVER_APP_VERSION") == "true" {
+		clientOpts = append(clientOpts, shuttle.WithAppVersionDiscovery())
+	}
+	cacheTTL := time.Minute
+	if ttl := os.Getenv("SHUTTLE_CACHE_TTL"); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatalf("Invalid SHUTTLE_CACHE_TTL %q: %v", ttl, err)
+		}
+		cacheTTL = parsed
+	}
+	clientOpts = append(clientOpts, shuttle.WithCache(cacheTTL))
+	maxWindowDays := 14
+	if days := os.Getenv("SHUTTLE_MAX_WINDOW_DAYS"); days != "" {
+		parsed, err := strconv.Atoi(days)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid SHUTTLE_MAX_WINDOW_DAYS %q", days)
+		}
+		maxWindowDays = parsed
+	}
+	clientOpts = append(clientOpts, shuttle.WithMaxWindowDays(maxWindowDays))
+	if list := os.Getenv("SHUTTLE_PROXIES"); list != "" {
+		proxies, err := shuttle.ParseProxies(list)
+		if err != nil {
+			log.Fatalf("Invalid SHUTTLE_PROXIES: %v", err)
+		}
+		strategy, err := shuttle.ParseProxyStrategy(os.Getenv("SHUTTLE_PROXY_STRATEGY"))
+		if err != nil {
+			log.Fatalf("Invalid SHUTTLE_PROXY_STRATEGY: %v", err)
+		}
+		log.Printf("Routing reservation API requests through %d egresses (%s)", len(proxies), strategy)
+		clientOpts = append(clientOpts, shuttle.WithProxies(proxies, strategy))
+	}
+	breaker := shuttle.NewCircuitBreaker(3, 10*time.Minute)
+	if threshold := os.Getenv("SHUTTLE_BREAKER_THRESHOLD"); threshold != "" {
+		parsed, err := strconv.Atoi(threshold)
+		if err != nil || parsed < 1 {
+			log.Fatalf("Invalid SHUTTLE_BREAKER_THRESHOLD %q", threshold)
+		}
+		breaker.FailureThreshold = parsed
+	}
+	if cooldown := os.Getenv("SHUTTLE_BREAKER_COOLDOWN"); cooldown != "" {
+		parsed, err := time.ParseDuration(cooldown)
+		if err != nil {
+			log.Fatalf("Invalid SHUTTLE_BREAKER_COOLDOWN %q: %v", cooldown, err)
+		}
+		breaker.OpenDuration = parsed
+	}
+	// Carry the breaker over between scheduled runs, so an outage is reported
+	// once and its end is noticed
+	if err := breaker.Restore(filepath.Join(stateDirectory(), "breaker.json")); err != nil {
+		log.Fatalf("Error loading circuit breaker state: %v", err)
+	}
+	clientOpts = append(clientOpts, shuttle.WithCircuitBreaker(breaker))
+	apiClient := shuttle.NewAPIClient(clientOpts...)
+
+	// Create a notifier for every configured channel. Mailgun and SMTP share
+	// the "email" name so routes don't depend on which one is set up.
+	templates, err := notification.LoadTemplates(os.Getenv("TEMPLATE_DIR"))
+	if err != nil {
+		log.Fatalf("Invalid notification templates: %v", err)
+	}
+	var channels []notification.Channel
+	if mailgunConfigured {
+		mailgunNotifier := notification.NewEmailNotifier(
+			mailgunDomain,
+			mailgunAPIKey,
+			recipientEmail,
+			senderEmail,
+		)
+		mailgunNotifier.Templates = templates
+		channels = append(channels, notification.Channel{Name: "email", Notifier: mailgunNotifier, Recipient: recipientEmail})
+	}
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		security, err := notification.ParseSMTPSecurity(os.Getenv("SMTP_SECURITY"))
+		if err != nil {
+			log.Fatalf("Invalid SMTP_SECURITY: %v", err)
+		}
+		var port int
+		if p := os.Getenv("SMTP_PORT"); p != "" {
+			port, err = strconv.Atoi(p)
+			if err != nil || port <= 0 {
+				log.Fatalf("Invalid SMTP_PORT %q", p)
+			}
+		}
+		from := os.Getenv("SMTP_FROM")
+		if from == "" {
+			from = senderEmail
+		}
+		to := splitList(os.Getenv("SMTP_TO"))
+		if len(to) == 0 {
+			to = splitList(recipientEmail)
+		}
+		if from == "" || len(to) == 0 {
+			log.Fatalf("SMTP_HOST requires SMTP_FROM and SMTP_TO (or SENDER_EMAIL and RECIPIENT_EMAIL)")
+		}
+		// One channel per recipient, so each can have its own quiet hours
+		for _, recipient := range to {
+			smtpNotifier := notification.NewSMTPNotifier(smtpHost, port, security, from, []string{recipient})
+			smtpNotifier.Username = os.Getenv("SMTP_USERNAME")
+			smtpNotifier.Password = os.Getenv("SMTP_PASSWORD")
+			smtpNotifier.Templates = templates
+			channels = append(channels, notification.Channel{Name: "email", Notifier: smtpNotifier, Recipient: recipient})
+		}
+	}
+
+	slackWebhook := os.Getenv("SLACK_WEBHOOK_URL")
+	slackWatchWebhooks, err := parseWatchMap(os.Getenv("SLACK_WATCH_WEBHOOKS"))
+	if err != nil {
+		log.Fatalf("Invalid SLACK_WATCH_WEBHOOKS: %v", err)
+	}
+	if slackWebhook != "" || len(slackWatchWebhooks) > 0 {
+		channels = append(channels, notification.Channel{Name: "slack", Notifier: notification.NewSlackNotifier(slackWebhook, slackWatchWebhooks)})
+	}
+	if discordWebhook := os.Getenv("DISCORD_WEBHOOK_URL"); discordWebhook != "" {
+		channels = append(channels, notification.Channel{Name: "discord", Notifier: notification.NewDiscordNotifier(discordWebhook)})
+	}
+	if telegramToken := os.Getenv("TELEGRAM_BOT_TOKEN"); telegramToken != "" {
+		chatIDs := splitList(os.Getenv("TELEGRAM_CHAT_IDS"))
+		if len(chatIDs) == 0 {
+			log.Fatalf("TELEGRAM_CHAT_IDS is required when TELEGRAM_BOT_TOKEN is set")
+		}
+		for _, chatID := range chatIDs {
+			telegram := notification.NewTelegramNotifier(telegramToken, os.Getenv("TELEGRAM_API_URL"), []string{chatID})
+			channels = append(channels, notification.Channel{Name: "telegram", Notifier: telegram, Recipient: chatID})
+		}
+	}
+	if ntfyTopic := os.Getenv("NTFY_TOPIC"); ntfyTopic != "" {
+		ntfy := notification.NewNtfyNotifier(os.Getenv("NTFY_SERVER"), ntfyTopic)
+		ntfy.Token = os.Getenv("NTFY_TOKEN")
+		ntfy.Username = os.Getenv("NTFY_USERNAME")
+		ntfy.Password = os.Getenv("NTFY_PASSWORD")
+		ntfy.Templates = templates
+		if within := os.Getenv("NTFY_URGENT_WITHIN"); within != "" {
+			parsed, err := time.ParseDuration(within)
+			if err != nil {
+				log.Fatalf("Invalid NTFY_URGENT_WITHIN %q: %v", within, err)
+			}
+			ntfy.UrgentWithin = parsed
+		}
+		channels = append(channels, notification.Channel{Name: "ntfy", Notifier: ntfy})
+	}
+	if webhookURLs := splitList(os.Getenv("WEBHOOK_URLS")); len(webhookURLs) > 0 {
+		secret := os.Getenv("WEBHOOK_SECRET")
+		if secret == "" {
+			log.Fatalf("WEBHOOK_SECRET is required when WEBHOOK_URLS is set")
+		}
+		webhook := notification.NewWebhookNotifier(webhookURLs, secret)
+		if retries := os.Getenv("WEBHOOK_MAX_RETRIES"); retries != "" {
+			parsed, err := strconv.Atoi(retries)
+			if err != nil || parsed < 0 {
+				log.Fatalf("Invalid WEBHOOK_MAX_RETRIES %q", retries)
+			}
+			webhook.MaxRetries = parsed
+		}
+		channels = append(channels, notification.Channel{Name: "webhook", Notifier: webhook})
+	}
+	if smsSID := os.Getenv("SMS_ACCOUNT_SID"); smsSID != "" {
+		smsFrom := os.Getenv("SMS_FROM")
+		smsTo := splitList(os.Getenv("SMS_TO"))
+		if smsFrom == "" || len(smsTo) == 0 {
+			log.Fatalf("SMS_FROM and SMS_TO are required when SMS_ACCOUNT_SID is set")
+		}
+		var smsMaxLength int
+		if maxLength := os.Getenv("SMS_MAX_LENGTH"); maxLength != "" {
+			parsed, err := strconv.Atoi(maxLength)
+			if err != nil || parsed < 0 {
+				log.Fatalf("Invalid SMS_MAX_LENGTH %q", maxLength)
+			}
+			smsMaxLength = parsed
+		}
+		for _, number := range smsTo {
+			sms := notification.NewSMSNotifier(os.Getenv("SMS_API_URL"), smsSID, os.Getenv("SMS_AUTH_TOKEN"), smsFrom, []string{number})
+			sms.ShortLinkBase = os.Getenv("PUBLIC_BASE_URL")
+			sms.MaxLength = smsMaxLength
+			channels = append(channels, notification.Channel{Name: "sms", Notifier: sms, Recipient: number})
+		}
+	}
+	if len(channels) == 0 {
+		log.Fatalf("No notification channels configured; set up Mailgun, SMTP or one of the other channels")
+	}
+
+	stateDir := stateDirectory()
+
+	// Follow up on openings in the same thread when the slots disappear
+	var slotGone bool
+	if value := os.Getenv("NOTIFY_SLOT_GONE"); value != "" {
+		slotGone, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("Invalid NOTIFY_SLOT_GONE %q", value)
+		}
+	}
+	if slotGone {
+		threads, err := notification.NewThreads(filepath.Join(stateDir, "threads.json"))
+		if err != nil {
+			log.Fatalf("Error loading message threads: %v", err)
+		}
+		for i, channel := range channels {
+			channels[i].Notifier = threads.Wrap(channel.Key(), channel.Notifier)
+		}
+	}
+
+	// Save every notification before it is sent, so failed ones are retried
+	outbox, err := notification.NewOutbox(outboxPath())
+	if err != nil {
+		log.Fatalf("Error loading notification outbox: %v", err)
+	}
+	if attempts := os.Getenv("OUTBOX_MAX_ATTEMPTS"); attempts != "" {
+		parsed, err := strconv.Atoi(attempts)
+		if err != nil || parsed < 1 {
+			log.Fatalf("Invalid OUTBOX_MAX_ATTEMPTS %q", attempts)
+		}
+		outbox.MaxAttempts = parsed
+	}
+	for i, channel := range channels {
+		channels[i].Notifier = outbox.Wrap(channel.Key(), channel.Notifier)
+	}
+
+	// Hold notifications for recipients in their quiet hours
+	quietHours, err := notification.NewQuietHours(filepath.Join(stateDir, "quiet.json"))
+	if err != nil {
+		log.Fatalf("Error loading held notifications: %v", err)
+	}
+	if within := os.Getenv("QUIET_URGENT_WITHIN"); within != "" {
+		parsed, err := time.ParseDuration(within)
+		if err != nil {
+			log.Fatalf("Invalid QUIET_URGENT_WITHIN %q: %v", within, err)
+		}
+		quietHours.UrgentWithin = parsed
+	}
+	quietWindows, err := parseWatchMap(os.Getenv("QUIET_HOURS"))
+	if err != nil {
+		log.Fatalf("Invalid QUIET_HOURS: %v", err)
+	}
+	for key, value := range quietWindows {
+		window, err := notification.ParseQuietWindow(value)
+		if err != nil {
+			log.Fatalf("Invalid QUIET_HOURS for %s: %v", key, err)
+		}
+		matched := false
+		for i, channel := range channels {
+			// A recipient's own window wins over its channel's
+			if channel.Key() == key || (channel.Name == key && quietWindows[channel.Key()] == "") {
+				channels[i].Notifier = quietHours.Wrap(channel.Key(), window, channel.Notifier)
+				matched = true
+			}
+		}
+		if !matched {
+			log.Fatalf("QUIET_HOURS refers to unknown channel or recipient %q", key)
+		}
+	}
+	// Retries wait for the end of quiet hours too
+	outbox.Hold = quietHours.Holds
+	routes, err := notification.ParseRoutes(os.Getenv("NOTIFY_ROUTES"))
+	if err != nil {
+		log.Fatalf("Invalid NOTIFY_ROUTES: %v", err)
+	}
+	fanout, err := notification.NewFanoutNotifier(channels, routes)
+	if err != nil {
+		log.Fatalf("Invalid NOTIFY_ROUTES: %v", err)
+	}
+
+	// Watches in digest mode are summarised periodically instead of notified
+	digestWatchList, err := parseWatchMap(os.Getenv("DIGEST_WATCHES"))
+	if err != nil {
+		log.Fatalf("Invalid DIGEST_WATCHES: %v", err)
+	}
+	digestWatches := make(map[string]notification.DigestSchedule)
+	for watchID, value := range digestWatchList {
+		schedule, err := notification.ParseDigestSchedule(value)
+		if err != nil {
+			log.Fatalf("Invalid DIGEST_WATCHES: %v", err)
+		}
+		digestWatches[watchID] = schedule
+	}
+	digests, err := notification.NewDigestNotifier(fanout, digestWatches, filepath.Join(stateDir, "digest.json"))
+	if err != nil {
+		log.Fatalf("Error loading digest queue: %v", err)
+	}
+	if at := os.Getenv("DIGEST_DAILY_AT"); at != "" {
+		parsed, err := time.Parse("15:04", at)
+		if err != nil {
+			log.Fatalf("Invalid DIGEST_DAILY_AT %q, expected HH:MM", at)
+		}
+		digests.DailyAt = time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
+	}
+	var notifier notification.Notifier = digests
+
+	// Remember what was already reported, so only changes are notified
+	trackerConfig := tracker.Config{}
+	if threshold := os.Getenv("NOTIFY_SEAT_THRESHOLD"); threshold != "" {
+		parsed, err := strconv.Atoi(threshold)
+		if err != nil || parsed < 1 {
+			log.Fatalf("Invalid NOTIFY_SEAT_THRESHOLD %q", threshold)
+		}
+		trackerConfig.SeatThreshold = parsed
+	}
+	if cooldown := os.Getenv("NOTIFY_COOLDOWN"); cooldown != "" {
+		parsed, err := time.ParseDuration(cooldown)
+		if err != nil {
+			log.Fatalf("Invalid NOTIFY_COOLDOWN %q: %v", cooldown, err)
+		}
+		trackerConfig.Cooldown = parsed
+	}
+	if interval := os.Getenv("NOTIFY_REMINDER_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil {
+			log.Fatalf("Invalid NOTIFY_REMINDER_INTERVAL %q: %v", interval, err)
+		}
+		trackerConfig.ReminderInterval = parsed
+	}
+	watchTracker, err := tracker.Open(filepath.Join(stateDir, "watches.json"), trackerConfig)
+	if err != nil {
+		log.Fatalf("Error loading watch state: %v", err)
+	}
 
-	// Create an email notifier
-	emailNotifier := notification.NewEmailNotifier(
-		mailgunDomain,
-		mailgunAPIKey,
-		recipientEmail,
-		senderEmail,
-	)
+	// Tell the operator once when the reservation API goes down and once when
+	// it recovers, rather than on every skipped check
+	breaker.OnStateChange = func(from, to shuttle.BreakerState, cause error) {
+		log.Printf("Reservation API circuit %s -> %s", from, to)
+		var subject, message string
+		switch {
+		case from == shuttle.BreakerClosed && to == shuttle.BreakerOpen:
+			subject = "Reservation API unavailable"
+			message = fmt.Sprintf("Availability checks are paused for %s after repeated failures.\n\nLast error: %v", breaker.OpenDuration, cause)
+		case to == shuttle.BreakerClosed:
+			subject = "Reservation API recovered"
+			message = "The reservation API is answering again and availability checks have resumed."
+		default:
+			return
+		}
+		alert := notification.Event{
+			Kind:       notification.KindAlert,
+			Title:      subject,
+			Message:    message,
+			DetectedAt: time.Now(),
+		}
+		if id, err := notifier.SendNotification(alert); err != nil {
+			log.Printf("Error sending operator alert: %v", err)
+		} else {
+			log.Printf("Operator alert sent, ID: %s", id)
+		}
+	}
+
+	c := &checker{
+		notifier:     notifier,
+		apiClient:    apiClient,
+		watchTracker: watchTracker,
+		digests:      digests,
+		quietHours:   quietHours,
+		slotGone:     slotGone,
+	}
 
 	// Set up routes
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -89,11 +462,26 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
-	// Create a closure to pass the emailNotifier to checkAllHandler
-	http.HandleFunc("/check-all", func(w http.ResponseWriter, r *http.Request) {
-		checkAllHandler(w, r, emailNotifier, apiClient)
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		metricsHandler(w, r, apiClient)
 	})
 
+	// Short booking links used in SMS messages
+	http.HandleFunc("GET /go/{watch}", bookingRedirectHandler)
+
+	http.HandleFunc("/check-all", c.checkAllHandler)
+
+	// The outbox holds events and error text and can resend them, so it is
+	// only served to callers with ADMIN_TOKEN
+	if adminToken := os.Getenv("ADMIN_TOKEN"); adminToken != "" {
+		http.HandleFunc("GET /outbox", requireToken(adminToken, func(w http.ResponseWriter, r *http.Request) {
+			outboxHandler(w, r, outbox)
+		}))
+		http.HandleFunc("POST /outbox/dead/{id}/retry", requireToken(adminToken, func(w http.ResponseWriter, r *http.Request) {
+			outboxRetryHandler(w, r, outbox)
+		}))
+	}
+
 	// Create a channel to signal shutdown
 	shutdownChan := make(chan struct{})
 
@@ -109,9 +497,18 @@ func main() {
 		}
 	}()
 
+	// Retry notifications that failed, including those left by earlier runs
+	go func() {
+		for ; ; time.Sleep(30 * time.Second) {
+			if err := outbox.Deliver(time.Now()); err != nil {
+				log.Printf("Error delivering notifications from the outbox: %v", err)
+			}
+		}
+	}()
+
 	// Run one check immediately
 	log.Println("Running initial availability check...")
-	checkAllLocations(emailNotifier, apiClient)
+	c.checkAllLocations()
 
 	// Set a timer for 5 minutes
 	shutdownTimer := time.NewTimer(5 * time.Minute)
@@ -128,58 +525,122 @@ func main() {
 	os.Exit(0)
 }
 
-func checkAllHandler(w http.ResponseWriter, r *http.Request, notifier notification.Notifier, apiClient *shuttle.APIClient) {
+// checker holds what a check of all locations needs
+type checker struct {
+	notifier     notification.Notifier
+	apiClient    *shuttle.APIClient
+	watchTracker *tracker.Tracker
+	digests      *notification.DigestNotifier
+	quietHours   *notification.QuietHours
+	// slotGone sends a follow-up when reported slots disappear
+	slotGone bool
+}
+
+func (c *checker) checkAllHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	// ?refresh=true skips the response cache for this check
+	refresh := r.URL != nil && r.URL.Query().Get("refresh") == "true"
+
 	var results []CheckResult
+	var pending []notification.Event
 	log.Println("Starting availability check for all locations...")
 
 	for _, location := range shuttle.Locations {
 		log.Printf("Checking %s...", location.Name)
 		
-		// Get the first and last date to check
 		if len(location.Dates) == 0 {
 			continue
 		}
-		startDate := location.Dates[0]
-		endDate := location.Dates[len(location.Dates)-1]
 
-		available, availableDates, err := apiClient.HasAvailability(
-			location.Name,
-			location.LocationID,
-			startDate,
-			endDate,
-			location.ResourceIDs,
-			location.BookingCategory,
-		)
+		availability, err := c.apiClient.CheckLocation(location, refresh)
+		url := bookingURL(location)
 		if err != nil {
-			log.Printf("Error checking availability for %s: %v", location.Name, err)
+			kind := shuttle.ErrorKind(err)
+			if errors.Is(err, shuttle.ErrCircuitOpen) {
+				log.Printf("Skipping %s, reservation API unavailable", location.Name)
+			} else {
+				log.Printf("Error checking availability for %s (%s): %v", location.Name, kind, err)
+			}
+			results = append(results, CheckResult{
+				WatchID:      location.ID,
+				Name:         location.Name,
+				URL:          url,
+				CheckedDates: location.Dates,
+				CheckedAt:    time.Now(),
+				Error:        err.Error(),
+				ErrorKind:    kind,
+			})
+			// Hammering the API after it pushed back only makes things worse,
+			// so skip the remaining locations until the next cycle
+			if errors.Is(err, shuttle.ErrRateLimited) || errors.Is(err, shuttle.ErrBlocked) || errors.Is(err, shuttle.ErrQueued) {
+				log.Printf("Reservation API is refusing requests, skipping remaining locations this cycle")
+				break
+			}
 			continue
 		}
 
+		availableDates := availability.AvailableDates()
+		available := len(availableDates) > 0
 		result := CheckResult{
+			WatchID:        location.ID,
 			Name:           location.Name,
-			URL:            fmt.Sprintf("https://reservation.pc.gc.ca/create-booking/results?resourceLocationId=%d", location.LocationID),
+			URL:            url,
 			Available:      available,
 			CheckedDates:   location.Dates,
 			AvailableDates: availableDates,
+			Dates:          availability.Statuses(),
 			CheckedAt:      time.Now(),
+			Cached:         availability.Cached,
+		}
+		if availability.Cached {
+			result.CacheAgeSeconds = int(availability.Age().Seconds())
 		}
 		results = append(results, result)
 		log.Printf("Check result for %s: %v (Available dates: %v)", location.Name, available, availableDates)
 
-		if available {
-			message := fmt.Sprintf("Slots available for %s on dates: %s", location.Name, strings.Join(availableDates, ", "))
-			log.Printf("%s, sending notification...", message)
-			if id, err := notifier.SendNotification(result.URL, message); err != nil {
-				log.Printf("Error sending notification for %s: %v", location.Name, err)
-			} else {
-				log.Printf("Notification sent successfully for %s, ID: %s", location.Name, id)
+		event := newEvent(notification.KindOpened, location, result)
+		decision, err := c.watchTracker.Observe(event, result.CheckedAt)
+		if err != nil {
+			log.Printf("Error saving watch state for %s: %v", location.Name, err)
+		}
+		if len(decision.Gone) > 0 {
+			// Digests report closings too, and instant follow-ups are optional
+			closed := newClosedEvent(event, decision.Gone)
+			if c.digests.Holds(closed) {
+				pending = append(pending, closed)
+			} else if c.slotGone {
+				log.Printf("Reported slots for %s are gone, sending follow-up...", location.Name)
+				pending = append(pending, closed)
+			}
+		}
+		if !decision.Notify {
+			if available {
+				log.Printf("Slots for %s already reported, not notifying", location.Name)
 			}
+			continue
+		}
+
+		event.Reminder = decision.Reminder
+		if decision.Reminder {
+			log.Printf("Slots for %s still available, sending reminder...", location.Name)
+		} else {
+			log.Printf("Slots available for %s on dates: %s, sending notification...", location.Name, strings.Join(availableDates, ", "))
 		}
+		pending = append(pending, event)
+	}
+
+	// Notify once every location is checked, so each message can summarise
+	// the other watches
+	c.notify(pending, results)
+	if err := c.digests.Flush(time.Now()); err != nil {
+		log.Printf("Error sending digest: %v", err)
+	}
+	if err := c.quietHours.Flush(time.Now()); err != nil {
+		log.Printf("Error delivering notifications held for quiet hours: %v", err)
 	}
 
 	log.Println("Availability check completed")
@@ -192,6 +653,140 @@ func checkAllHandler(w http.ResponseWriter, r *http.Request, notifier notificati
 	json.NewEncoder(w).Encode(response)
 }
 
-func checkAllLocations(notifier notification.Notifier, apiClient *shuttle.APIClient) {
-	checkAllHandler(&dummyResponseWriter{}, &http.Request{Method: http.MethodGet}, notifier, apiClient)
+// notify sends the events of a check cycle and remembers which openings were
+// reported. Notifications the outbox queued for a retry count as sent, or the
+// next cycle would report the same slots again.
+func (c *checker) notify(pending []notification.Event, results []CheckResult) {
+	for _, event := range pending {
+		event.Watching = watchSummaries(results, event.WatchID)
+		id, err := c.notifier.SendNotification(event)
+		switch {
+		case err != nil && !notification.Queued(err):
+			log.Printf("Error sending notification for %s: %v", event.Watch, err)
+			continue
+		case err != nil:
+			log.Printf("Notification for %s sent with failures that will be retried, ID: %s: %v", event.Watch, id, err)
+		case c.digests.Holds(event):
+			log.Printf("Added %s (%s) to the %s digest", event.Watch, event.Kind, c.digests.Watches[event.WatchID])
+		default:
+			log.Printf("Notification sent successfully for %s, ID: %s", event.Watch, id)
+		}
+		if event.Kind == notification.KindOpened {
+			if err := c.watchTracker.MarkNotified(event.WatchID, time.Now()); err != nil {
+				log.Printf("Error saving watch state for %s: %v", event.Watch, err)
+			}
+		}
+	}
+}
+
+// bookingURL returns the reservation site's results page for location
+func bookingURL(location shuttle.Location) string {
+	return fmt.Sprintf("https://reservation.pc.gc.ca/create-booking/results?resourceLocationId=%d", location.LocationID)
+}
+
+// bookingRedirectHandler sends /go/<watch-id> to the watch's booking page, so
+// length-limited channels can carry a short link
+func bookingRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	watchID := r.PathValue("watch")
+	for _, location := range shuttle.Locations {
+		if location.ID == watchID {
+			http.Redirect(w, r, bookingURL(location), http.StatusFound)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// newEvent describes a check result of location as a notification event
+func newEvent(kind notification.EventKind, location shuttle.Location, result CheckResult) notification.Event {
+	event := notification.Event{
+		Kind:       kind,
+		WatchID:    location.ID,
+		Watch:      location.Name,
+		Location:   location.Place,
+		LocationID: location.LocationID,
+		BookingURL: result.URL,
+		DetectedAt: result.CheckedAt,
+	}
+	for _, status := range result.Dates {
+		date := notification.DateAvailability{Date: status.Date, State: status.State}
+		if status.State == shuttle.StateAvailable {
+			date.BookingURL = fmt.Sprintf("%s&startDate=%s&endDate=%s", result.URL, status.Date, status.Date)
+		}
+		for _, resource := range status.Resources {
+			date.Departures = append(date.Departures, notification.Departure{
+				ResourceID: resource.ResourceID,
+				State:      resource.State,
+				Seats:      resource.Seats,
+			})
+		}
+		event.Dates = append(event.Dates, date)
+	}
+	return event
+}
+
+// newClosedEvent describes departures of an observed event that are no
+// longer open, with how long each stayed open
+func newClosedEvent(observed notification.Event, gone []tracker.Slot) notification.Event {
+	closed := observed
+	closed.Kind = notification.KindClosed
+	closed.Reminder = false
+	closed.Dates = nil
+	for _, slot := range gone {
+		state := shuttle.StateUnknown
+		dep := notification.Departure{ResourceID: slot.ResourceID, State: shuttle.StateUnknown}
+		for _, date := range observed.Dates {
+			if date.Date != slot.Date {
+				continue
+			}
+			state = date.State
+			for _, d := range date.Departures {
+				if d.ResourceID == slot.ResourceID {
+					dep = d
+				}
+			}
+		}
+		if !slot.OpenSince.IsZero() {
+			dep.OpenFor = observed.DetectedAt.Sub(slot.OpenSince)
+		}
+		if n := len(closed.Dates); n > 0 && closed.Dates[n-1].Date == slot.Date {
+			closed.Dates[n-1].Departures = append(closed.Dates[n-1].Departures, dep)
+			continue
+		}
+		closed.Dates = append(closed.Dates, notification.DateAvailability{Date: slot.Date, State: state, Departures: []notification.Departure{dep}})
+	}
+	return closed
+}
+
+// watchSummaries describes every watch except exclude from this cycle's results
+func watchSummaries(results []CheckResult, exclude string) []notification.WatchSummary {
+	var summaries []notification.WatchSummary
+	for _, location := range shuttle.Locations {
+		if location.ID == exclude || len(location.Dates) == 0 {
+			continue
+		}
+		summary := notification.WatchSummary{
+			WatchID:    location.ID,
+			Watch:      location.Name,
+			Dates:      location.Dates,
+			BookingURL: bookingURL(location),
+			Error:      "not checked",
+		}
+		for _, result := range results {
+			if result.WatchID != location.ID {
+				continue
+			}
+			summary.AvailableDates = result.AvailableDates
+			summary.Error = ""
+			if result.Error != "" {
+				summary.Error = "check failed: " + strings.ReplaceAll(result.ErrorKind, "_", " ")
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func (c *checker) checkAllLocations() {
+	c.checkAllHandler(&dummyResponseWriter{}, &http.Request{Method: http.MethodGet})
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/notification"
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/BohdanMelnyk/bus-shulter-checker/tracker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingNotifier counts the notifications it was asked to send
+type countingNotifier struct {
+	mu   sync.Mutex
+	err  error
+	sent int
+}
+
+func (n *countingNotifier) SendNotification(event notification.Event) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent++
+	return "id", n.err
+}
+
+func TestChecker_QueuedNotificationsCountAsSent(t *testing.T) {
+	dir := t.TempDir()
+	outbox, err := notification.NewOutbox(filepath.Join(dir, "outbox.json"))
+	require.NoError(t, err)
+	working, failing := &countingNotifier{}, &countingNotifier{err: errors.New("smtp is down")}
+	fanout, err := notification.NewFanoutNotifier([]notification.Channel{
+		{Name: "slack", Notifier: outbox.Wrap("slack", working)},
+		{Name: "email", Notifier: outbox.Wrap("email", failing)},
+	}, nil)
+	require.NoError(t, err)
+	digests, err := notification.NewDigestNotifier(fanout, nil, filepath.Join(dir, "digest.json"))
+	require.NoError(t, err)
+	watchTracker, err := tracker.Open("", tracker.Config{})
+	require.NoError(t, err)
+	c := &checker{notifier: digests, watchTracker: watchTracker, digests: digests}
+
+	event := notification.Event{
+		Kind:    notification.KindOpened,
+		WatchID: "lake-ohara",
+		Watch:   "Lake O'Hara",
+		Dates: []notification.DateAvailability{{Date: "2025-08-05", State: shuttle.StateAvailable, Departures: []notification.Departure{
+			{ResourceID: 1, State: shuttle.StateAvailable, Seats: 2},
+		}}},
+	}
+	now := time.Now()
+	for cycle := 0; cycle < 2; cycle++ {
+		decision, err := watchTracker.Observe(event, now.Add(time.Duration(cycle)*time.Minute))
+		require.NoError(t, err)
+		if decision.Notify {
+			c.notify([]notification.Event{event}, nil)
+		}
+	}
+
+	// The failed channel is left to the outbox, so the second cycle sends nothing
+	assert.Equal(t, 1, working.sent)
+	assert.Equal(t, 1, failing.sent)
+	assert.Len(t, outbox.Pending(), 1)
+}
+
+func TestRequireToken(t *testing.T) {
+	handler := requireToken("s3cret", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusNoContent,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/outbox", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		assert.Equal(t, want, rec.Code, header)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+)
+
+// metricsHandler exposes client counters in the Prometheus text format
+func metricsHandler(w http.ResponseWriter, r *http.Request, apiClient *shuttle.APIClient) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	cache := apiClient.CacheStats()
+	writeMetric(w, "shuttle_cache_hits_total", "counter", "Availability queries answered from the response cache", cache.Hits)
+	writeMetric(w, "shuttle_cache_misses_total", "counter", "Availability queries that missed the response cache", cache.Misses)
+	writeMetric(w, "shuttle_cache_evictions_total", "counter", "Expired entries removed from the response cache", cache.Evictions)
+	writeMetric(w, "shuttle_cache_entries", "gauge", "Entries currently held in the response cache", cache.Entries)
+
+	writeHeader(w, "shuttle_circuit_state", "gauge", "Reservation API circuit breaker state (1 for the current state)")
+	for _, state := range []shuttle.BreakerState{shuttle.BreakerClosed, shuttle.BreakerOpen, shuttle.BreakerHalfOpen} {
+		fmt.Fprintf(w, "shuttle_circuit_state{state=%q} %d\n", state, boolToInt(apiClient.BreakerState() == state))
+	}
+
+	if proxies := apiClient.ProxyStats(); len(proxies) > 0 {
+		writeHeader(w, "shuttle_proxy_requests_total", "counter", "Requests sent through each egress")
+		for _, p := range proxies {
+			fmt.Fprintf(w, "shuttle_proxy_requests_total{proxy=%q} %d\n", p.Proxy, p.Requests)
+		}
+		writeHeader(w, "shuttle_proxy_failures_total", "counter", "Requests through each egress that failed because of the egress")
+		for _, p := range proxies {
+			fmt.Fprintf(w, "shuttle_proxy_failures_total{proxy=%q} %d\n", p.Proxy, p.Failures)
+		}
+		writeHeader(w, "shuttle_proxy_healthy", "gauge", "Whether each egress is currently used (1) or cooling down (0)")
+		for _, p := range proxies {
+			fmt.Fprintf(w, "shuttle_proxy_healthy{proxy=%q} %d\n", p.Proxy, boolToInt(p.Healthy))
+		}
+	}
+}
+
+func writeMetric(w http.ResponseWriter, name, kind, help string, value any) {
+	writeHeader(w, name, kind, help)
+	fmt.Fprintf(w, "%s %v\n", name, value)
+}
+
+func writeHeader(w http.ResponseWriter, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
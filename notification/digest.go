@@ -0,0 +1,190 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/BohdanMelnyk/bus-shulter-checker/store"
+)
+
+// DigestSchedule says how often a digest is sent
+type DigestSchedule string
+
+const (
+	DigestHourly DigestSchedule = "hourly"
+	DigestDaily  DigestSchedule = "daily"
+)
+
+// ParseDigestSchedule parses "hourly" or "daily"
+func ParseDigestSchedule(value string) (DigestSchedule, error) {
+	switch s := DigestSchedule(strings.ToLower(strings.TrimSpace(value))); s {
+	case DigestHourly, DigestDaily:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown digest schedule %q, expected hourly or daily", value)
+	}
+}
+
+// digestQueue is what a watch has collected since its last digest
+type digestQueue struct {
+	Schedule DigestSchedule `json:"schedule"`
+	Events   []Event        `json:"events"`
+	// Since is when the period covered by the next digest started
+	Since time.Time `json:"since"`
+}
+
+// DigestNotifier implements the Notifier interface by holding openings and
+// closings of digest watches and sending them to Next as one summary per
+// watch, so that routes apply to digests too. Queued events are saved to
+// disk, so they survive restarts. Other events are passed straight to Next.
+type DigestNotifier struct {
+	Next Notifier
+	// Watches maps the IDs of digest watches to their schedule
+	Watches map[string]DigestSchedule
+	// DailyAt is when daily digests go out, as time since midnight in the
+	// parks' time zone
+	DailyAt time.Duration
+
+	path string
+	mu   sync.Mutex
+	// queues holds the queue of each watch ID
+	queues map[string]*digestQueue
+}
+
+// NewDigestNotifier creates a DigestNotifier keeping its queue at path
+func NewDigestNotifier(next Notifier, watches map[string]DigestSchedule, path string) (*DigestNotifier, error) {
+	d := &DigestNotifier{
+		Next:    next,
+		Watches: watches,
+		DailyAt: 8 * time.Hour,
+		path:    path,
+		queues:  make(map[string]*digestQueue),
+	}
+	if err := store.Load(path, &d.queues); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Holds reports whether the event will be queued for a digest rather than sent
+func (d *DigestNotifier) Holds(event Event) bool {
+	_, ok := d.Watches[event.WatchID]
+	return ok && (event.Kind == KindOpened || event.Kind == KindClosed)
+}
+
+// SendNotification queues events of digest watches and sends the rest on
+func (d *DigestNotifier) SendNotification(event Event) (string, error) {
+	if !d.Holds(event) {
+		return d.Next.SendNotification(event)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	q, ok := d.queues[event.WatchID]
+	if !ok {
+		q = &digestQueue{}
+		d.queues[event.WatchID] = q
+	}
+	q.Schedule = d.Watches[event.WatchID]
+	if q.Since.IsZero() {
+		q.Since = event.DetectedAt
+		if q.Since.IsZero() {
+			q.Since = time.Now()
+		}
+	}
+	// The digest lists other watches itself
+	event.Watching = nil
+	q.Events = append(q.Events, event)
+	if err := store.Save(d.path, d.queues); err != nil {
+		return "", fmt.Errorf("error queueing event for digest: %w", err)
+	}
+	return "", nil
+}
+
+// Flush sends every digest that is due at now. A digest that fails to send
+// stays queued and is tried again on the next flush, unless the outbox took
+// it for retry.
+func (d *DigestNotifier) Flush(now time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	watchIDs := make([]string, 0, len(d.queues))
+	for watchID := range d.queues {
+		watchIDs = append(watchIDs, watchID)
+	}
+	sort.Strings(watchIDs)
+
+	var errs []error
+	sent := false
+	for _, watchID := range watchIDs {
+		q := d.queues[watchID]
+		if len(q.Events) == 0 || now.Before(d.due(q.Schedule, q.Since)) {
+			continue
+		}
+		if _, err := d.Next.SendNotification(digestEvent(q.Schedule, q.Events, now)); err != nil && !Queued(err) {
+			errs = append(errs, fmt.Errorf("%s digest for %s: %w", q.Schedule, watchID, err))
+			continue
+		}
+		delete(d.queues, watchID)
+		sent = true
+	}
+	if sent {
+		if err := store.Save(d.path, d.queues); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// due returns when the digest for a period that started at since goes out:
+// at the next full hour, or at the next DailyAt in the parks' time zone
+func (d *DigestNotifier) due(schedule DigestSchedule, since time.Time) time.Time {
+	if schedule == DigestHourly {
+		return since.Truncate(time.Hour).Add(time.Hour)
+	}
+	local := since.In(shuttle.ParkTimeZone)
+	at := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, shuttle.ParkTimeZone).Add(d.DailyAt)
+	if !at.After(since) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at
+}
+
+// digestEvent bundles the queued events of a watch into one digest event
+func digestEvent(schedule DigestSchedule, events []Event, now time.Time) Event {
+	entries := append([]Event(nil), events...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].DetectedAt.Before(entries[j].DetectedAt) })
+
+	opened, closed := 0, 0
+	for _, e := range entries {
+		if e.Kind == KindClosed {
+			closed++
+		} else {
+			opened++
+		}
+	}
+	first := entries[0]
+	event := Event{
+		Kind:       KindDigest,
+		WatchID:    first.WatchID,
+		Watch:      first.Watch,
+		Title:      fmt.Sprintf("%s shuttle digest for %s: %s, %s", strings.ToUpper(string(schedule[:1]))+string(schedule[1:]), first.Watch, countNoun(opened, "opening"), countNoun(closed, "closing")),
+		DetectedAt: now,
+		Digest:     entries,
+	}
+	event.Message = event.Text()
+	return event
+}
+
+// countNoun formats a count with a singular or plural noun, e.g. "1 opening"
+func countNoun(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
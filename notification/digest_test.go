@@ -0,0 +1,119 @@
+package notification
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digest.json")
+	next := &recordingNotifier{id: "sent"}
+	watches := map[string]DigestSchedule{"moraine-midday": DigestHourly}
+	digest, err := NewDigestNotifier(next, watches, path)
+	require.NoError(t, err)
+	start := time.Date(2025, 7, 1, 8, 10, 0, 0, time.UTC)
+
+	// Other watches go straight through
+	id, err := digest.SendNotification(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "sent", id)
+	require.Len(t, next.events, 1)
+
+	opened := testEvent()
+	opened.WatchID = "moraine-midday"
+	opened.Watch = "Lake Morain Midday"
+	opened.DetectedAt = start
+	assert.True(t, digest.Holds(opened))
+	id, err = digest.SendNotification(opened)
+	require.NoError(t, err)
+	assert.Empty(t, id)
+
+	// The queue survives a restart
+	digest, err = NewDigestNotifier(next, watches, path)
+	require.NoError(t, err)
+	closed := opened
+	closed.Kind = KindClosed
+	closed.DetectedAt = start.Add(20 * time.Minute)
+	_, err = digest.SendNotification(closed)
+	require.NoError(t, err)
+
+	require.NoError(t, digest.Flush(start.Add(40*time.Minute)))
+	assert.Len(t, next.events, 1, "not due before the full hour")
+
+	require.NoError(t, digest.Flush(start.Add(50*time.Minute)))
+	require.Len(t, next.events, 2)
+	sent := next.events[1]
+	assert.Equal(t, KindDigest, sent.Kind)
+	assert.Equal(t, "moraine-midday", sent.WatchID)
+	assert.Equal(t, "Hourly shuttle digest for Lake Morain Midday: 1 opening, 1 closing", sent.Subject())
+	require.Len(t, sent.Digest, 2)
+	assert.Equal(t, KindOpened, sent.Digest[0].Kind)
+	assert.Contains(t, sent.Message, "Lake Morain Midday: available on 2025-08-05")
+	assert.Contains(t, sent.Message, "Lake Morain Midday: no longer available on 2025-08-05")
+
+	// Nothing left to send
+	require.NoError(t, digest.Flush(start.Add(3*time.Hour)))
+	assert.Len(t, next.events, 2)
+}
+
+func TestDigestNotifier_KeepsQueueOnFailure(t *testing.T) {
+	next := &recordingNotifier{err: errors.New("smtp down")}
+	digest, err := NewDigestNotifier(next, map[string]DigestSchedule{"w": DigestDaily}, filepath.Join(t.TempDir(), "digest.json"))
+	require.NoError(t, err)
+
+	event := testEvent()
+	event.WatchID = "w"
+	event.DetectedAt = time.Date(2025, 7, 1, 20, 0, 0, 0, time.UTC)
+	_, err = digest.SendNotification(event)
+	require.NoError(t, err)
+
+	due := time.Date(2025, 7, 2, 8, 0, 0, 0, shuttle.ParkTimeZone)
+	assert.Error(t, digest.Flush(due))
+	next.err = nil
+	require.NoError(t, digest.Flush(due.Add(30*time.Minute)))
+	assert.Len(t, next.events, 2)
+	assert.Len(t, next.events[1].Digest, 1)
+}
+
+func TestDigestNotifier_Due(t *testing.T) {
+	digest := &DigestNotifier{DailyAt: 8 * time.Hour}
+	park := shuttle.ParkTimeZone
+
+	before := time.Date(2025, 7, 1, 6, 0, 0, 0, park)
+	assert.Equal(t, time.Date(2025, 7, 1, 8, 0, 0, 0, park), digest.due(DigestDaily, before))
+	after := time.Date(2025, 7, 1, 9, 0, 0, 0, park)
+	assert.Equal(t, time.Date(2025, 7, 2, 8, 0, 0, 0, park), digest.due(DigestDaily, after))
+	assert.Equal(t, time.Date(2025, 7, 1, 10, 0, 0, 0, park), digest.due(DigestHourly, after.Add(5*time.Minute)))
+}
+
+func TestDigestNotifier_FollowsRoutes(t *testing.T) {
+	email, sms := &recordingNotifier{}, &recordingNotifier{}
+	fanout, err := NewFanoutNotifier([]Channel{{Name: "email", Notifier: email}, {Name: "sms", Notifier: sms}},
+		[]Route{{WatchID: "lake-ohara", Channels: []string{"sms"}}, {WatchID: "moraine-midday", Channels: []string{"email"}}})
+	require.NoError(t, err)
+	watches := map[string]DigestSchedule{"lake-ohara": DigestHourly, "moraine-midday": DigestHourly}
+	digest, err := NewDigestNotifier(fanout, watches, filepath.Join(t.TempDir(), "digest.json"))
+	require.NoError(t, err)
+	start := time.Date(2025, 7, 1, 8, 10, 0, 0, time.UTC)
+
+	for watchID := range watches {
+		event := testEvent()
+		event.WatchID = watchID
+		event.DetectedAt = start
+		_, err = digest.SendNotification(event)
+		require.NoError(t, err)
+	}
+	require.NoError(t, digest.Flush(start.Add(time.Hour)))
+
+	// Each watch gets its own digest, sent where its route says
+	require.Len(t, email.events, 1)
+	assert.Equal(t, "moraine-midday", email.events[0].WatchID)
+	require.Len(t, sms.events, 1)
+	assert.Equal(t, "lake-ohara", sms.events[0].WatchID)
+}
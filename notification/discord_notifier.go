@@ -0,0 +1,244 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+)
+
+// Embed colours by availability state, and for events without dates
+const (
+	discordColorAvailable   = 0x2ECC71
+	discordColorNotReleased = 0xF1C40F
+	discordColorSoldOut     = 0xE74C3C
+	discordColorUnknown     = 0x95A5A6
+	discordColorDigest      = 0x3498DB
+	discordColorAlert       = 0xE67E22
+)
+
+// discordStateColors lists the colour of each state, from the most to the
+// least promising
+var discordStateColors = []struct {
+	state shuttle.SlotState
+	color int
+}{
+	{shuttle.StateAvailable, discordColorAvailable},
+	{shuttle.StateNotReleased, discordColorNotReleased},
+	{shuttle.StateSoldOut, discordColorSoldOut},
+}
+
+// discordMaxFields is the most fields Discord accepts in one embed
+const discordMaxFields = 25
+
+// DiscordNotifier implements the Notifier interface by posting embeds to a
+// Discord webhook. It honours Discord's rate-limit headers and retries after
+// 429 responses.
+type DiscordNotifier struct {
+	WebhookURL string
+	// MaxRetries is how many times a rate-limited message is retried
+	MaxRetries int
+	Client     *http.Client
+
+	// sleep waits out rate limits; replaced in tests
+	sleep func(time.Duration)
+
+	mu sync.Mutex
+	// notBefore is when the current rate-limit bucket resets, if it is exhausted
+	notBefore time.Time
+}
+
+// NewDiscordNotifier creates a new DiscordNotifier posting to the given webhook
+func NewDiscordNotifier(webhookURL string) *DiscordNotifier {
+	return &DiscordNotifier{
+		WebhookURL: webhookURL,
+		MaxRetries: 3,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		sleep:      time.Sleep,
+	}
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+}
+
+type discordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds"`
+}
+
+// SendNotification posts the event as an embed and returns the Discord message ID
+func (d *DiscordNotifier) SendNotification(event Event) (string, error) {
+	payload, err := json.Marshal(discordMessage{Embeds: []discordEmbed{discordEmbedFor(event)}})
+	if err != nil {
+		return "", fmt.Errorf("error marshaling Discord message: %w", err)
+	}
+
+	// wait=true makes Discord return the created message, including its ID.
+	// The webhook URL may already have a query, e.g. thread_id.
+	webhook, err := url.Parse(d.WebhookURL)
+	if err != nil {
+		// The URL contains the webhook token, keep it out of logs
+		return "", fmt.Errorf("invalid Discord webhook URL: %w", errors.Unwrap(err))
+	}
+	query := webhook.Query()
+	query.Set("wait", "true")
+	webhook.RawQuery = query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		d.waitForBucket()
+
+		resp, err := d.Client.Post(webhook.String(), "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return "", fmt.Errorf("error posting to Discord: %w", errors.Unwrap(err))
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		d.trackBucket(resp.Header)
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if attempt >= d.MaxRetries {
+				return "", fmt.Errorf("rate limited by Discord after %d retries", attempt)
+			}
+			d.sleep(retryAfter(resp.Header, body))
+			continue
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			return "", fmt.Errorf("unexpected status code from Discord: %d, body: %s", resp.StatusCode, string(body))
+		}
+
+		var created struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(body, &created)
+		return created.ID, nil
+	}
+}
+
+// waitForBucket sleeps until the rate-limit bucket resets if the last
+// response said it was exhausted
+func (d *DiscordNotifier) waitForBucket() {
+	d.mu.Lock()
+	wait := time.Until(d.notBefore)
+	d.mu.Unlock()
+	if wait > 0 {
+		d.sleep(wait)
+	}
+}
+
+func (d *DiscordNotifier) trackBucket(header http.Header) {
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	resetAfter, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset-After"), 64)
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	d.notBefore = time.Now().Add(time.Duration(resetAfter * float64(time.Second)))
+	d.mu.Unlock()
+}
+
+// discordColorFor returns the colour of the most promising state in the
+// event. Departures are used where a date lists them, so closed events are
+// coloured by the slots that went rather than by the rest of the day.
+func discordColorFor(event Event) int {
+	states := make(map[shuttle.SlotState]bool)
+	for _, date := range event.Dates {
+		if len(date.Departures) == 0 {
+			states[date.State] = true
+		}
+		for _, dep := range date.Departures {
+			states[dep.State] = true
+		}
+	}
+	for _, sc := range discordStateColors {
+		if states[sc.state] {
+			return sc.color
+		}
+	}
+	return discordColorUnknown
+}
+
+// retryAfter reads how long to wait after a 429, preferring the precise
+// retry_after in the body over the Retry-After header
+func retryAfter(header http.Header, body []byte) time.Duration {
+	var limited struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if json.Unmarshal(body, &limited) == nil && limited.RetryAfter > 0 {
+		return time.Duration(limited.RetryAfter * float64(time.Second))
+	}
+	if seconds, err := strconv.ParseFloat(header.Get("Retry-After"), 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return time.Second
+}
+
+// discordEmbedFor builds an embed coloured by status, with a field for every
+// date and departure and a link to the booking page
+func discordEmbedFor(event Event) discordEmbed {
+	embed := discordEmbed{
+		Title: truncate(event.Subject(), 256),
+		URL:   event.BookingURL,
+	}
+	if !event.DetectedAt.IsZero() {
+		embed.Timestamp = event.DetectedAt.UTC().Format(time.RFC3339)
+	}
+
+	switch event.Kind {
+	case KindAlert:
+		embed.Color = discordColorAlert
+		embed.Description = event.Message
+		return embed
+	case KindDigest:
+		embed.Color = discordColorDigest
+		embed.Description = truncate(event.Message, 4096)
+		return embed
+	}
+	embed.Color = discordColorFor(event)
+
+	if event.Location != "" {
+		embed.Description = event.Location
+	}
+	if event.BookingURL != "" && event.Kind != KindClosed {
+		embed.Description += fmt.Sprintf("\n[Book now](%s)", event.BookingURL)
+	}
+
+	for _, date := range event.Dates {
+		if date.State != shuttle.StateAvailable {
+			embed.Fields = append(embed.Fields, discordField{Name: date.Date, Value: stateLabel(date.State), Inline: true})
+			continue
+		}
+		for _, dep := range date.Departures {
+			embed.Fields = append(embed.Fields, discordField{
+				Name:   date.Date + " · " + dep.Name(),
+				Value:  seatCount(dep.Seats),
+				Inline: true,
+			})
+		}
+	}
+	if len(embed.Fields) > discordMaxFields {
+		embed.Fields = embed.Fields[:discordMaxFields]
+	}
+	return embed
+}
@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscordNotifier_SendNotification(t *testing.T) {
+	var msg discordMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("wait"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1234567890"}`))
+	}))
+	defer server.Close()
+
+	id, err := NewDiscordNotifier(server.URL).SendNotification(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "1234567890", id)
+
+	require.Len(t, msg.Embeds, 1)
+	embed := msg.Embeds[0]
+	assert.Equal(t, discordColorAvailable, embed.Color)
+	assert.Equal(t, testEvent().BookingURL, embed.URL)
+	assert.Contains(t, embed.Description, "[Book now]")
+	assert.Equal(t, []discordField{
+		{Name: "2025-08-05 · 6:30 AM", Value: "2 seats", Inline: true},
+		{Name: "2025-08-05 · Departure -2147476634", Value: "1 seat", Inline: true},
+		{Name: "2025-08-06", Value: "not yet released", Inline: true},
+	}, embed.Fields)
+}
+
+func TestDiscordNotifier_KeepsWebhookQuery(t *testing.T) {
+	var query map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewDiscordNotifier(server.URL + "/api/webhooks/1/token?thread_id=42").SendNotification(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"thread_id": {"42"}, "wait": {"true"}}, query)
+}
+
+func TestDiscordNotifier_RetriesAfterRateLimit(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message":"You are being rate limited.","retry_after":0.75,"global":false}`))
+			return
+		}
+		// Last request of the bucket
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset-After", "30")
+		w.Write([]byte(`{"id":"42"}`))
+	}))
+	defer server.Close()
+
+	var slept []time.Duration
+	notifier := NewDiscordNotifier(server.URL)
+	notifier.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	id, err := notifier.SendNotification(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "42", id)
+	assert.Equal(t, []time.Duration{750 * time.Millisecond}, slept)
+
+	// The next message waits for the exhausted bucket to reset
+	_, err = notifier.SendNotification(testEvent())
+	require.NoError(t, err)
+	require.Len(t, slept, 2)
+	assert.InDelta(t, float64(30*time.Second), float64(slept[1]), float64(time.Second))
+}
+
+func TestDiscordNotifier_HidesWebhookInErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	_, err := NewDiscordNotifier(server.URL + "/api/webhooks/1/secret").SendNotification(testEvent())
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
+
+func TestDiscordEmbedFor_ColoursByStatus(t *testing.T) {
+	assert.Equal(t, discordColorAvailable, discordEmbedFor(testEvent()).Color)
+
+	notReleased := testEvent()
+	notReleased.Dates = notReleased.Dates[1:]
+	assert.Equal(t, discordColorNotReleased, discordEmbedFor(notReleased).Color)
+
+	// Closed events go by the departures that went, not by the rest of the day
+	closed := testClosedEvent()
+	closed.Dates[0].State = shuttle.StateAvailable
+	assert.Equal(t, discordColorSoldOut, discordEmbedFor(closed).Color)
+
+	unknown := testEvent()
+	unknown.Dates = []DateAvailability{{Date: "2025-08-05", State: shuttle.StateUnknown}}
+	assert.Equal(t, discordColorUnknown, discordEmbedFor(unknown).Color)
+
+	assert.Equal(t, discordColorAlert, discordEmbedFor(Event{Kind: KindAlert}).Color)
+}
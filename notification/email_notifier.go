@@ -2,8 +2,8 @@ package notification
 
 import (
 	"context"
-	"fmt"
 	"github.com/mailgun/mailgun-go/v4"
+	"strings"
 	"time"
 )
 
@@ -13,6 +13,8 @@ type EmailNotifier struct {
 	APIKey    string
 	Recipient string
 	Sender    string
+	// Templates renders the message; nil uses the built-in templates
+	Templates *Templates
 }
 
 // NewEmailNotifier creates a new EmailNotifier with the given configuration
@@ -25,15 +27,28 @@ func NewEmailNotifier(domain, apiKey, recipient, sender string) *EmailNotifier {
 	}
 }
 
-// SendNotification sends an email notification about an available shuttle slot
-func (e *EmailNotifier) SendNotification(url string, locationName string) (string, error) {
+// SendNotification sends an email describing the event
+func (e *EmailNotifier) SendNotification(event Event) (string, error) {
+	msg, err := e.Templates.orBuiltin().Render("email", event)
+	if err != nil {
+		return "", err
+	}
+
+	subject, inReplyTo := emailReply(msg.Subject, event)
 	mg := mailgun.NewMailgun(e.Domain, e.APIKey)
 	m := mailgun.NewMessage(
 		e.Sender,
-		fmt.Sprintf("Shuttle Slot Available for %s", locationName),
-		fmt.Sprintf("A shuttle slot is now available for booking at %s.\n\nBooking URL: %s", locationName, url),
+		subject,
+		msg.Text,
 		e.Recipient,
 	)
+	if msg.HTML != "" {
+		m.SetHtml(msg.HTML)
+	}
+	if inReplyTo != "" {
+		m.AddHeader("In-Reply-To", inReplyTo)
+		m.AddHeader("References", inReplyTo)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
@@ -41,3 +56,24 @@ func (e *EmailNotifier) SendNotification(url string, locationName string) (strin
 	_, id, err := mg.Send(ctx, m)
 	return id, err
 }
+
+// RenderSubject returns the subject SendNotification uses for the event
+func (e *EmailNotifier) RenderSubject(event Event) (string, error) {
+	msg, err := e.Templates.orBuiltin().Render("email", event)
+	return msg.Subject, err
+}
+
+// emailReply returns the subject and In-Reply-To message ID for an event that
+// follows up on an earlier email, so mail clients show both in one thread.
+// Other events keep their subject and get no In-Reply-To.
+func emailReply(subject string, event Event) (string, string) {
+	thread := event.Thread
+	if thread == nil || !strings.HasPrefix(thread.MessageID, "<") || strings.ContainsAny(thread.MessageID, "\r\n") {
+		return subject, ""
+	}
+	if thread.Subject != "" {
+		// Gmail only threads replies with the same subject
+		subject = "Re: " + thread.Subject
+	}
+	return subject, thread.MessageID
+}
@@ -15,7 +15,11 @@ func TestEmailNotifier_SendNotification(t *testing.T) {
 	)
 
 	// Test sending notification
-	id, err := notifier.SendNotification("https://test.com", "Test Location")
+	id, err := notifier.SendNotification(Event{
+		Kind:       KindOpened,
+		Watch:      "Test Location",
+		BookingURL: "https://test.com",
+	})
 	
 	// We expect an error since we're using dummy credentials
 	assert.Error(t, err)
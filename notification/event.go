@@ -0,0 +1,236 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+)
+
+// EventKind says what happened to a watch
+type EventKind string
+
+const (
+	// KindOpened means seats became available
+	KindOpened EventKind = "opened"
+	// KindClosed means previously reported seats are gone
+	KindClosed EventKind = "closed"
+	// KindDigest bundles several events into one summary
+	KindDigest EventKind = "digest"
+	// KindAlert is an operator alert about the checker itself, such as the
+	// reservation API being down; only Title and Message are set
+	KindAlert EventKind = "alert"
+)
+
+// Departure is the availability of one resource (a departure time) on a date
+type Departure struct {
+	ResourceID int64             `json:"resourceId"`
+	Label      string            `json:"label,omitempty"`
+	State      shuttle.SlotState `json:"state"`
+	Seats      int               `json:"seats"`
+	// OpenFor is how long a departure in a closed event stayed bookable
+	OpenFor time.Duration `json:"openFor,omitempty"`
+}
+
+// Name returns the departure label, falling back to its resource ID
+func (d Departure) Name() string {
+	if d.Label != "" {
+		return d.Label
+	}
+	return fmt.Sprintf("Departure %d", d.ResourceID)
+}
+
+// DateAvailability is the availability of a watch on one date
+type DateAvailability struct {
+	Date       string            `json:"date"`
+	State      shuttle.SlotState `json:"state"`
+	Departures []Departure       `json:"departures"`
+	// BookingURL opens the booking page for this date, if known
+	BookingURL string `json:"bookingUrl,omitempty"`
+}
+
+// Seats returns the number of bookable seats over all departures
+func (d DateAvailability) Seats() int {
+	seats := 0
+	for _, dep := range d.Departures {
+		seats += dep.Seats
+	}
+	return seats
+}
+
+// WatchSummary is a short status of another watch, sent along with an event so
+// recipients can see what else is being watched
+type WatchSummary struct {
+	WatchID        string   `json:"watchId"`
+	Watch          string   `json:"watch"`
+	Dates          []string `json:"dates"`
+	AvailableDates []string `json:"availableDates,omitempty"`
+	BookingURL     string   `json:"bookingUrl,omitempty"`
+	// Error says why the watch has no current status
+	Error string `json:"error,omitempty"`
+}
+
+// Thread identifies the message a follow-up replies to on one channel
+type Thread struct {
+	// MessageID is the ID the channel returned for the original message
+	MessageID string `json:"messageId"`
+	Subject   string `json:"subject,omitempty"`
+}
+
+// Event is everything a channel needs to tell someone about a watch
+type Event struct {
+	Kind EventKind `json:"kind"`
+	// WatchID is the stable key of the watch, used for per-watch settings
+	WatchID string `json:"watchId,omitempty"`
+	// Watch is the name of the watch, e.g. "Lake Morain Morning"
+	Watch string `json:"watch,omitempty"`
+	// Location is the destination served by the watch, e.g. "Moraine Lake"
+	Location   string `json:"location,omitempty"`
+	LocationID int    `json:"locationId,omitempty"`
+	// Dates lists every watched date with its state, not only the available ones
+	Dates      []DateAvailability `json:"dates,omitempty"`
+	BookingURL string             `json:"bookingUrl,omitempty"`
+	DetectedAt time.Time          `json:"detectedAt"`
+	// Reminder marks a repeat notification for slots that are still open
+	Reminder bool `json:"reminder,omitempty"`
+	// Watching summarises the other watches at the time of the event
+	Watching []WatchSummary `json:"watching,omitempty"`
+
+	// Thread is the notification a closed event follows up on, on the
+	// channel it is sent to; set by Threads
+	Thread *Thread `json:"thread,omitempty"`
+
+	// Digest lists the openings and closings summarised by a digest
+	Digest []Event `json:"digest,omitempty"`
+
+	// Title and Message carry the text of operator alerts and digests
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// AvailableDates returns the dates that can be booked right now
+func (e Event) AvailableDates() []DateAvailability {
+	return e.datesInState(shuttle.StateAvailable)
+}
+
+// AvailableWithin reports whether any available date starts within d of now.
+// Dates are taken to start at midnight in the parks' time zone; a date that
+// is already under way counts as within any window.
+func (e Event) AvailableWithin(now time.Time, d time.Duration) bool {
+	for _, date := range e.AvailableDates() {
+		start, err := shuttle.ParseDateTime(date.Date)
+		if err != nil {
+			continue
+		}
+		end := start.AddDate(0, 0, 1)
+		if end.After(now) && start.Before(now.Add(d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenFor returns the longest time any departure of a closed event stayed
+// bookable, or zero if unknown
+func (e Event) OpenFor() time.Duration {
+	var longest time.Duration
+	for _, date := range e.Dates {
+		for _, dep := range date.Departures {
+			longest = max(longest, dep.OpenFor)
+		}
+	}
+	return longest
+}
+
+func (e Event) datesInState(state shuttle.SlotState) []DateAvailability {
+	var dates []DateAvailability
+	for _, d := range e.Dates {
+		if d.State == state {
+			dates = append(dates, d)
+		}
+	}
+	return dates
+}
+
+func dateList(dates []DateAvailability) string {
+	names := make([]string, 0, len(dates))
+	for _, d := range dates {
+		names = append(names, d.Date)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Subject returns a one-line headline for the event, rendered from the
+// built-in templates
+func (e Event) Subject() string {
+	return e.renderBuiltin().Subject
+}
+
+// Text returns a plain-text rendering of the event for channels without
+// rich formatting, from the built-in templates
+func (e Event) Text() string {
+	return e.renderBuiltin().Text
+}
+
+// renderBuiltin renders the event with the built-in templates. They are
+// checked against every kind of event when the package loads, so a failure
+// here is a bug.
+func (e Event) renderBuiltin() Message {
+	msg, err := builtinTemplates.Render("", e)
+	if err != nil {
+		panic(fmt.Sprintf("built-in templates failed on a %s event: %v", e.Kind, err))
+	}
+	return msg
+}
+
+// stateLabel returns a human-readable description of a slot state
+func stateLabel(state shuttle.SlotState) string {
+	switch state {
+	case shuttle.StateAvailable:
+		return "available"
+	case shuttle.StateSoldOut:
+		return "sold out"
+	case shuttle.StateNotReleased:
+		return "not yet released"
+	case shuttle.StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// duration formats how long something lasted to the minute, e.g.
+// "12 minutes" or "2 hours 5 minutes"
+func duration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	days, hours, minutes := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	var parts []string
+	for _, p := range []struct {
+		n    int
+		unit string
+	}{{days, "day"}, {hours, "hour"}, {minutes, "minute"}} {
+		switch {
+		case p.n == 1:
+			parts = append(parts, "1 "+p.unit)
+		case p.n > 1:
+			parts = append(parts, fmt.Sprintf("%d %ss", p.n, p.unit))
+		}
+	}
+	// Minutes don't matter once it's been days
+	if days > 0 && len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, " ")
+}
+
+// seatCount formats a number of seats, e.g. "1 seat" or "3 seats"
+func seatCount(n int) string {
+	if n == 1 {
+		return "1 seat"
+	}
+	return fmt.Sprintf("%d seats", n)
+}
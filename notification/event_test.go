@@ -0,0 +1,48 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEvent() Event {
+	return Event{
+		Kind:       KindOpened,
+		Watch:      "Lake Morain Morning",
+		Location:   "Moraine Lake",
+		LocationID: -2147483642,
+		Dates: []DateAvailability{
+			{Date: "2025-08-05", State: shuttle.StateAvailable, Departures: []Departure{
+				{ResourceID: -2147476652, Label: "6:30 AM", State: shuttle.StateAvailable, Seats: 2},
+				{ResourceID: -2147476634, State: shuttle.StateAvailable, Seats: 1},
+			}},
+			{Date: "2025-08-06", State: shuttle.StateNotReleased},
+		},
+		BookingURL: "https://reservation.pc.gc.ca/create-booking/results?resourceLocationId=-2147483642",
+	}
+}
+
+func TestEventSubject(t *testing.T) {
+	assert.Equal(t, "Shuttle slots available for Lake Morain Morning on 2025-08-05", testEvent().Subject())
+}
+
+func TestEventReminder(t *testing.T) {
+	event := testEvent()
+	event.Reminder = true
+	assert.Equal(t, "Shuttle slots still available for Lake Morain Morning on 2025-08-05", event.Subject())
+	assert.True(t, strings.HasPrefix(event.Text(), "Shuttle slots are still available for Lake Morain Morning"))
+}
+
+func TestEventText(t *testing.T) {
+	text := testEvent().Text()
+
+	assert.True(t, strings.HasPrefix(text, "Shuttle slots are available for Lake Morain Morning at Moraine Lake."))
+	assert.Contains(t, text, "2025-08-05: available")
+	assert.Contains(t, text, "  6:30 AM: 2 seats")
+	assert.Contains(t, text, "  Departure -2147476634: 1 seat")
+	assert.Contains(t, text, "2025-08-06: not yet released")
+	assert.Contains(t, text, "Booking URL: https://reservation.pc.gc.ca/")
+}
@@ -0,0 +1,220 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Channel is a notifier registered under a name that routes refer to.
+// Several channels may share a name, e.g. "email" for Mailgun and SMTP.
+type Channel struct {
+	Name     string
+	Notifier Notifier
+	// Recipient identifies who the channel reaches when that's narrower than
+	// the channel, e.g. one phone number; used for per-recipient settings
+	Recipient string
+}
+
+// Key returns "name:recipient", or the name when there is no recipient
+func (c Channel) Key() string {
+	if c.Recipient == "" {
+		return c.Name
+	}
+	return c.Name + ":" + c.Recipient
+}
+
+// Route sends matching events to a set of channels. A route matches when all
+// of its conditions hold; an empty condition always holds.
+type Route struct {
+	// WatchID limits the route to one watch
+	WatchID string
+	// Within limits the route to events with a date available within this long
+	Within time.Duration
+	// Channels lists channel names; "*" means every channel
+	Channels []string
+}
+
+// matches reports whether the event satisfies the route's conditions
+func (r Route) matches(event Event, now time.Time) bool {
+	if r.WatchID != "" && r.WatchID != event.WatchID {
+		return false
+	}
+	if r.Within > 0 && !event.AvailableWithin(now, r.Within) {
+		return false
+	}
+	return true
+}
+
+// Delivery is the outcome of sending an event through one channel
+type Delivery struct {
+	Channel  string
+	ID       string
+	Err      error
+	Duration time.Duration
+}
+
+// FanoutNotifier implements the Notifier interface by sending each event to
+// several channels at once, chosen by routes. An event goes to the union of the
+// channels of every matching route, or to every channel if no route matches.
+type FanoutNotifier struct {
+	Channels []Channel
+	Routes   []Route
+
+	// now is the clock used for Within conditions; replaced in tests
+	now func() time.Time
+}
+
+// NewFanoutNotifier creates a FanoutNotifier, checking that every route names
+// a configured channel
+func NewFanoutNotifier(channels []Channel, routes []Route) (*FanoutNotifier, error) {
+	for _, route := range routes {
+		for _, name := range route.Channels {
+			if name == "*" {
+				continue
+			}
+			if !slices.ContainsFunc(channels, func(c Channel) bool { return c.Name == name }) {
+				return nil, fmt.Errorf("route for %s refers to unknown channel %q", route.describe(), name)
+			}
+		}
+	}
+	return &FanoutNotifier{Channels: channels, Routes: routes, now: time.Now}, nil
+}
+
+// Send delivers the event to every selected channel concurrently, so a slow or
+// failing channel doesn't hold up the others. Results are in channel order.
+func (f *FanoutNotifier) Send(event Event) []Delivery {
+	selected := f.channelsFor(event)
+	deliveries := make([]Delivery, len(selected))
+
+	var wg sync.WaitGroup
+	for i, ch := range selected {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			id, err := ch.Notifier.SendNotification(event)
+			deliveries[i] = Delivery{Channel: ch.Name, ID: id, Err: err, Duration: time.Since(start)}
+		}()
+	}
+	wg.Wait()
+	return deliveries
+}
+
+// SendNotification sends the event through its channels. The returned id lists
+// the channels' ids as name:id pairs; failed channels are reported in err.
+func (f *FanoutNotifier) SendNotification(event Event) (string, error) {
+	var ids []string
+	var errs []error
+	for _, d := range f.Send(event) {
+		if d.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d.Channel, d.Err))
+			continue
+		}
+		if d.ID != "" {
+			ids = append(ids, d.Channel+":"+d.ID)
+		}
+	}
+	return strings.Join(ids, ","), errors.Join(errs...)
+}
+
+// channelsFor returns the channels an event should be sent to. Closed events
+// follow up on an earlier notification and go to every channel; channels
+// wrapped by Threads only send them where the closed slots were reported.
+func (f *FanoutNotifier) channelsFor(event Event) []Channel {
+	if event.Kind == KindClosed {
+		return f.Channels
+	}
+
+	now := time.Now
+	if f.now != nil {
+		now = f.now
+	}
+
+	names := make(map[string]bool)
+	matched := false
+	for _, route := range f.Routes {
+		if !route.matches(event, now()) {
+			continue
+		}
+		matched = true
+		for _, name := range route.Channels {
+			if name == "*" {
+				return f.Channels
+			}
+			names[name] = true
+		}
+	}
+	if !matched {
+		return f.Channels
+	}
+
+	var selected []Channel
+	for _, ch := range f.Channels {
+		if names[ch.Name] {
+			selected = append(selected, ch)
+		}
+	}
+	return selected
+}
+
+func (r Route) describe() string {
+	var conds []string
+	if r.WatchID != "" {
+		conds = append(conds, r.WatchID)
+	}
+	if r.Within > 0 {
+		conds = append(conds, "within:"+r.Within.String())
+	}
+	if len(conds) == 0 {
+		return "every event"
+	}
+	return strings.Join(conds, "&")
+}
+
+// ParseRoutes parses routing rules separated by semicolons. Each rule is
+// "<conditions>=<channels>", where conditions are a watch ID, "within:<duration>"
+// or both joined with "&", and channels are names joined with "+" or "*" for
+// all, e.g. "lake-ohara=sms+slack; moraine-midday=email; within:48h=*".
+func ParseRoutes(value string) ([]Route, error) {
+	var routes []Route
+	for _, rule := range strings.Split(value, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		conds, channels, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected conditions=channels, got %q", rule)
+		}
+
+		var route Route
+		for _, cond := range strings.Split(conds, "&") {
+			cond = strings.TrimSpace(cond)
+			switch {
+			case cond == "" || cond == "*":
+			case strings.HasPrefix(cond, "within:"):
+				d, err := time.ParseDuration(strings.TrimPrefix(cond, "within:"))
+				if err != nil || d <= 0 {
+					return nil, fmt.Errorf("invalid duration in %q", rule)
+				}
+				route.Within = d
+			default:
+				route.WatchID = cond
+			}
+		}
+		for _, name := range strings.Split(channels, "+") {
+			if name = strings.TrimSpace(name); name != "" {
+				route.Channels = append(route.Channels, name)
+			}
+		}
+		if len(route.Channels) == 0 {
+			return nil, fmt.Errorf("no channels in %q", rule)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
@@ -0,0 +1,93 @@
+package notification
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier remembers the events it was asked to send
+type recordingNotifier struct {
+	mu     sync.Mutex
+	id     string
+	err    error
+	delay  time.Duration
+	events []Event
+}
+
+func (r *recordingNotifier) SendNotification(event Event) (string, error) {
+	time.Sleep(r.delay)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return r.id, r.err
+}
+
+func TestFanoutNotifier_Routes(t *testing.T) {
+	email, sms, slack := &recordingNotifier{id: "e"}, &recordingNotifier{id: "s"}, &recordingNotifier{id: "k"}
+	routes, err := ParseRoutes("lake-ohara=sms+slack; moraine-midday=email; within:48h=*")
+	require.NoError(t, err)
+	fanout, err := NewFanoutNotifier([]Channel{{Name: "email", Notifier: email}, {Name: "sms", Notifier: sms}, {Name: "slack", Notifier: slack}}, routes)
+	require.NoError(t, err)
+	fanout.now = func() time.Time { return time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC) }
+
+	event := testEvent() // available on 2025-08-05, days away
+	event.WatchID = "lake-ohara"
+	id, err := fanout.SendNotification(event)
+	require.NoError(t, err)
+	assert.Equal(t, "sms:s,slack:k", id)
+
+	event.WatchID = "moraine-midday"
+	id, _ = fanout.SendNotification(event)
+	assert.Equal(t, "email:e", id)
+
+	// A date within 48h goes everywhere, whatever the watch
+	event.Dates[0].Date = "2025-08-02"
+	id, _ = fanout.SendNotification(event)
+	assert.Equal(t, "email:e,sms:s,slack:k", id)
+
+	// Events no route matches, such as operator alerts, go everywhere
+	id, _ = fanout.SendNotification(Event{Kind: KindAlert, Title: "down"})
+	assert.Equal(t, "email:e,sms:s,slack:k", id)
+}
+
+func TestFanoutNotifier_ReportsEachChannel(t *testing.T) {
+	slow := &recordingNotifier{id: "slow", delay: 50 * time.Millisecond}
+	broken := &recordingNotifier{err: errors.New("boom")}
+	fast := &recordingNotifier{id: "fast"}
+	fanout, err := NewFanoutNotifier([]Channel{{Name: "slow", Notifier: slow}, {Name: "broken", Notifier: broken}, {Name: "fast", Notifier: fast}}, nil)
+	require.NoError(t, err)
+
+	deliveries := fanout.Send(testEvent())
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, "slow", deliveries[0].ID)
+	assert.EqualError(t, deliveries[1].Err, "boom")
+	assert.Equal(t, "fast", deliveries[2].ID)
+	// Channels are sent concurrently, so the fast one doesn't wait for the slow one
+	assert.Less(t, deliveries[2].Duration, 50*time.Millisecond)
+
+	id, err := fanout.SendNotification(testEvent())
+	assert.Equal(t, "slow:slow,fast:fast", id)
+	assert.EqualError(t, err, "broken: boom")
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes("lake-ohara & within:72h = sms ; *=email")
+	require.NoError(t, err)
+	assert.Equal(t, []Route{
+		{WatchID: "lake-ohara", Within: 72 * time.Hour, Channels: []string{"sms"}},
+		{Channels: []string{"email"}},
+	}, routes)
+
+	for _, bad := range []string{"lake-ohara", "lake-ohara=", "within:soon=sms"} {
+		_, err := ParseRoutes(bad)
+		assert.Error(t, err, bad)
+	}
+
+	_, err = NewFanoutNotifier([]Channel{{Name: "email", Notifier: &recordingNotifier{}}}, []Route{{WatchID: "lake-ohara", Channels: []string{"pager"}}})
+	assert.ErrorContains(t, err, `unknown channel "pager"`)
+}
@@ -2,12 +2,17 @@ package notification
 
 // Notifier is an interface for sending notifications
 type Notifier interface {
-	// SendNotification sends a notification about availability
+	// SendNotification delivers an event through the notifier's channel
 	// parameters:
-	//   - url: the URL where availability was found
-	//   - locationName: the name of the location that has availability
+	//   - event: what happened, with the watch, dates, seats and booking URL
 	// returns:
 	//   - id: a unique identifier for the sent notification (if applicable)
 	//   - error: any error that occurred during notification sending
-	SendNotification(url string, locationName string) (id string, err error)
+	SendNotification(event Event) (id string, err error)
+}
+
+// subjectRenderer is implemented by notifiers whose messages have a subject
+// line, so that follow-ups can reply to the subject that was actually sent
+type subjectRenderer interface {
+	RenderSubject(event Event) (string, error)
 }
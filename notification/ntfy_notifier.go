@@ -0,0 +1,123 @@
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultNtfyServer is the public ntfy instance
+const DefaultNtfyServer = "https://ntfy.sh"
+
+// ntfy message priorities, see https://docs.ntfy.sh/publish/#message-priority
+const (
+	ntfyPriorityLow     = 2
+	ntfyPriorityDefault = 3
+	ntfyPriorityHigh    = 4
+	// ntfyPriorityMax is the only level that can break through do-not-disturb
+	ntfyPriorityMax = 5
+)
+
+// NtfyNotifier implements the Notifier interface by publishing to an ntfy topic
+type NtfyNotifier struct {
+	ServerURL string
+	Topic     string
+	// Token is an access token; if empty, Username and Password are used for
+	// basic auth when set
+	Token    string
+	Username string
+	Password string
+	// UrgentWithin raises openings for dates this close to maximum priority
+	UrgentWithin time.Duration
+	// Templates renders the title and body; nil uses the built-in templates
+	Templates *Templates
+	Client    *http.Client
+
+	// now returns the current time; replaced in tests
+	now func() time.Time
+}
+
+// NewNtfyNotifier creates a new NtfyNotifier. An empty serverURL uses DefaultNtfyServer.
+func NewNtfyNotifier(serverURL, topic string) *NtfyNotifier {
+	if serverURL == "" {
+		serverURL = DefaultNtfyServer
+	}
+	return &NtfyNotifier{
+		ServerURL:    strings.TrimRight(serverURL, "/"),
+		Topic:        topic,
+		UrgentWithin: 72 * time.Hour,
+		Client:       &http.Client{Timeout: 30 * time.Second},
+		now:          time.Now,
+	}
+}
+
+// SendNotification publishes the event and returns the ntfy message ID
+func (n *NtfyNotifier) SendNotification(event Event) (string, error) {
+	msg, err := n.Templates.orBuiltin().Render("ntfy", event)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", n.ServerURL+"/"+n.Topic, strings.NewReader(msg.Text))
+	if err != nil {
+		// The topic name is the only secret on public servers, keep it out of logs
+		return "", fmt.Errorf("error creating request: %w", errors.Unwrap(err))
+	}
+
+	priority, tags := n.priorityAndTags(event)
+	req.Header.Set("Title", msg.Subject)
+	req.Header.Set("Priority", strconv.Itoa(priority))
+	req.Header.Set("Tags", strings.Join(tags, ","))
+	if event.BookingURL != "" && event.Kind != KindClosed {
+		req.Header.Set("Click", event.BookingURL)
+		req.Header.Set("Actions", "view, Book now, "+event.BookingURL)
+	}
+
+	switch {
+	case n.Token != "":
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	case n.Username != "":
+		req.SetBasicAuth(n.Username, n.Password)
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error publishing to ntfy: %w", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code from ntfy: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var published struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(body, &published)
+	return published.ID, nil
+}
+
+// priorityAndTags picks the message priority and emoji tags for the event.
+// Openings for dates within UrgentWithin get the maximum priority, so the
+// phone rings even in do-not-disturb mode.
+func (n *NtfyNotifier) priorityAndTags(event Event) (int, []string) {
+	switch event.Kind {
+	case KindAlert:
+		return ntfyPriorityHigh, []string{"warning"}
+	case KindClosed:
+		return ntfyPriorityDefault, []string{"bus", "x"}
+	case KindDigest:
+		return ntfyPriorityLow, []string{"bus", "calendar"}
+	}
+
+	if n.UrgentWithin > 0 && event.AvailableWithin(n.now(), n.UrgentWithin) {
+		return ntfyPriorityMax, []string{"bus", "rotating_light"}
+	}
+	return ntfyPriorityHigh, []string{"bus", "white_check_mark"}
+}
@@ -0,0 +1,73 @@
+package notification
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNtfyNotifier_SendNotification(t *testing.T) {
+	var headers http.Header
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/shuttles", r.URL.Path)
+		headers = r.Header.Clone()
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		w.Write([]byte(`{"id":"sPs71M8A2T","time":1754352000,"event":"message","topic":"shuttles"}`))
+	}))
+	defer server.Close()
+
+	notifier := NewNtfyNotifier(server.URL, "shuttles")
+	notifier.Token = "tk_secret"
+	// Two weeks before the first available date
+	notifier.now = func() time.Time { return time.Date(2025, 7, 22, 12, 0, 0, 0, shuttle.ParkTimeZone) }
+
+	id, err := notifier.SendNotification(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "sPs71M8A2T", id)
+
+	assert.Equal(t, "Bearer tk_secret", headers.Get("Authorization"))
+	assert.Equal(t, testEvent().Subject(), headers.Get("Title"))
+	assert.Equal(t, "4", headers.Get("Priority"))
+	assert.Equal(t, "bus,white_check_mark", headers.Get("Tags"))
+	assert.Equal(t, testEvent().BookingURL, headers.Get("Click"))
+	assert.Equal(t, testEvent().Text(), body)
+}
+
+func TestNtfyNotifier_NearTermDateIsUrgent(t *testing.T) {
+	var priority string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		priority = r.Header.Get("Priority")
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "alice", user)
+		assert.Equal(t, "hunter2", pass)
+		w.Write([]byte(`{"id":"x"}`))
+	}))
+	defer server.Close()
+
+	notifier := NewNtfyNotifier(server.URL, "shuttles")
+	notifier.Username, notifier.Password = "alice", "hunter2"
+	// The evening before the available date
+	notifier.now = func() time.Time { return time.Date(2025, 8, 4, 21, 0, 0, 0, shuttle.ParkTimeZone) }
+
+	_, err := notifier.SendNotification(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "5", priority)
+}
+
+func TestNtfyNotifier_HidesTopicInErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	_, err := NewNtfyNotifier(server.URL, "secret-topic").SendNotification(testEvent())
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret-topic")
+}
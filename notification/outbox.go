@@ -0,0 +1,321 @@
+package notification
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/store"
+)
+
+// ErrNotDeadLetter is returned by Outbox.Retry for an unknown entry ID
+var ErrNotDeadLetter = errors.New("no such dead letter")
+
+// ErrNotPending is returned by Outbox.DeliverOne for an entry that isn't
+// waiting to be sent, including one that is being sent already
+var ErrNotPending = errors.New("no such pending notification")
+
+// RetryError is returned for a notification that failed but stays in the
+// outbox for another attempt
+type RetryError struct {
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	return "queued for retry: " + e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Queued reports whether every failure in err is a RetryError, i.e. the
+// outbox will deliver the notification later and the caller must not resend it
+func Queued(err error) bool {
+	switch e := err.(type) {
+	case *RetryError:
+		return true
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			if !Queued(inner) {
+				return false
+			}
+		}
+		return true
+	case interface{ Unwrap() error }:
+		return Queued(e.Unwrap())
+	}
+	return false
+}
+
+// OutboxEntry is a notification waiting to be delivered to one channel
+type OutboxEntry struct {
+	ID          string    `json:"id"`
+	Channel     string    `json:"channel"`
+	Event       Event     `json:"event"`
+	CreatedAt   time.Time `json:"created_at"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	// InFlight is set while an attempt is under way, so the entry isn't sent
+	// twice however long the attempt takes
+	InFlight bool `json:"in_flight,omitempty"`
+}
+
+type outboxState struct {
+	Pending []OutboxEntry `json:"pending"`
+	Dead    []OutboxEntry `json:"dead"`
+}
+
+// Outbox saves every notification to disk before it is sent, and retries
+// failed ones with exponential backoff until they succeed or run out of
+// attempts. Notifications that run out are kept as dead letters, which can
+// be inspected and retried.
+type Outbox struct {
+	// MaxAttempts is how many times a notification is tried before it becomes
+	// a dead letter
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles with every
+	// attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Hold, if set, keeps Deliver from retrying an entry for now, e.g. during
+	// the channel's quiet hours; it gets the entry's channel key
+	Hold func(channel string, event Event, now time.Time) bool
+
+	path      string
+	mu        sync.Mutex
+	state     outboxState
+	notifiers map[string]Notifier
+
+	// now returns the current time; replaced in tests
+	now func() time.Time
+}
+
+// NewOutbox creates an Outbox keeping its entries at path
+func NewOutbox(path string) (*Outbox, error) {
+	o := &Outbox{
+		MaxAttempts: 8,
+		Backoff:     time.Minute,
+		MaxBackoff:  time.Hour,
+		path:        path,
+		notifiers:   make(map[string]Notifier),
+		now:         time.Now,
+	}
+	if err := store.Load(path, &o.state); err != nil {
+		return nil, err
+	}
+	// Attempts saved as in flight were cut short when the process stopped
+	for i := range o.state.Pending {
+		o.state.Pending[i].InFlight = false
+	}
+	return o, nil
+}
+
+// Wrap returns a notifier that records events in the outbox before sending
+// them through next. key names the channel across restarts.
+func (o *Outbox) Wrap(key string, next Notifier) Notifier {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.notifiers[key] = next
+	return &outboxNotifier{outbox: o, key: key, next: next}
+}
+
+// Pending returns the notifications waiting to be delivered
+func (o *Outbox) Pending() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]OutboxEntry(nil), o.state.Pending...)
+}
+
+// Dead returns the notifications that ran out of attempts
+func (o *Outbox) Dead() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]OutboxEntry(nil), o.state.Dead...)
+}
+
+// Retry moves the dead letter with the given ID back into the outbox, with a
+// fresh set of attempts. It is sent on the next Deliver.
+func (o *Outbox) Retry(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, entry := range o.state.Dead {
+		if entry.ID != id {
+			continue
+		}
+		o.state.Dead = append(o.state.Dead[:i:i], o.state.Dead[i+1:]...)
+		entry.Attempts = 0
+		entry.NextAttempt = o.now()
+		o.state.Pending = append(o.state.Pending, entry)
+		return store.Save(o.path, o.state)
+	}
+	return fmt.Errorf("%w: %s", ErrNotDeadLetter, id)
+}
+
+// Deliver sends every pending notification that is due and not held. Failures
+// are rescheduled or moved to the dead letters, and returned.
+func (o *Outbox) Deliver(now time.Time) error {
+	// Hold is asked without holding o.mu, as it may take locks of its own
+	held := make(map[string]bool)
+	if o.Hold != nil {
+		for _, entry := range o.Pending() {
+			held[entry.ID] = o.Hold(entry.Channel, entry.Event, now)
+		}
+	}
+	due := o.claim(func(entry OutboxEntry) bool { return !entry.NextAttempt.After(now) && !held[entry.ID] })
+	return o.send(due, now)
+}
+
+// DeliverOne sends the pending notification with the given ID, whether or not
+// it is due, and returns the outcome of that attempt
+func (o *Outbox) DeliverOne(id string, now time.Time) error {
+	due := o.claim(func(entry OutboxEntry) bool { return entry.ID == id })
+	if len(due) == 0 {
+		return fmt.Errorf("%w: %s", ErrNotPending, id)
+	}
+	return o.send(due, now)
+}
+
+// claim marks the pending entries that match as in flight and returns them.
+// Entries already in flight are skipped.
+func (o *Outbox) claim(match func(OutboxEntry) bool) []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var claimed []OutboxEntry
+	for i, entry := range o.state.Pending {
+		if !entry.InFlight && match(entry) {
+			o.state.Pending[i].InFlight = true
+			claimed = append(claimed, o.state.Pending[i])
+		}
+	}
+	return claimed
+}
+
+// send makes an attempt to send each of the claimed entries
+func (o *Outbox) send(entries []OutboxEntry, now time.Time) error {
+	var errs []error
+	for _, entry := range entries {
+		o.mu.Lock()
+		next, ok := o.notifiers[entry.Channel]
+		o.mu.Unlock()
+
+		err := fmt.Errorf("channel %s is not configured", entry.Channel)
+		if ok {
+			_, err = next.SendNotification(entry.Event)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Channel, err))
+		}
+		if _, saveErr := o.finish(entry, err, now); saveErr != nil {
+			errs = append(errs, saveErr)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// finish records the outcome of an attempt to send entry: it is removed on
+// success, and otherwise rescheduled or moved to the dead letters. dead
+// reports the latter.
+func (o *Outbox) finish(entry OutboxEntry, sendErr error, now time.Time) (dead bool, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, pending := range o.state.Pending {
+		if pending.ID != entry.ID {
+			continue
+		}
+		o.state.Pending = append(o.state.Pending[:i:i], o.state.Pending[i+1:]...)
+		entry.InFlight = false
+		if sendErr != nil {
+			entry.Attempts++
+			entry.LastError = sendErr.Error()
+			dead = entry.Attempts >= o.MaxAttempts
+			if dead {
+				o.state.Dead = append(o.state.Dead, entry)
+			} else {
+				entry.NextAttempt = now.Add(o.backoff(entry.Attempts))
+				o.state.Pending = append(o.state.Pending, entry)
+			}
+		}
+		return dead, store.Save(o.path, o.state)
+	}
+	return false, nil
+}
+
+// backoff returns the wait after the given number of failed attempts
+func (o *Outbox) backoff(attempts int) time.Duration {
+	wait := o.Backoff
+	for i := 1; i < attempts && wait < o.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if o.MaxBackoff > 0 && wait > o.MaxBackoff {
+		wait = o.MaxBackoff
+	}
+	return wait
+}
+
+// outboxNotifier is the Notifier returned by Outbox.Wrap
+type outboxNotifier struct {
+	outbox *Outbox
+	key    string
+	next   Notifier
+}
+
+// SendNotification saves the event to the outbox and makes the first
+// attempt to send it. If that fails, the error is a RetryError, or the
+// attempt's error if the entry could not be kept.
+func (n *outboxNotifier) SendNotification(event Event) (string, error) {
+	o := n.outbox
+	id, err := newOutboxID()
+	if err != nil {
+		return "", err
+	}
+	now := o.now()
+	// Deliver leaves the entry alone while the first attempt is under way;
+	// if the process stops before it finishes, the entry is retried later
+	entry := OutboxEntry{ID: id, Channel: n.key, Event: event, CreatedAt: now, NextAttempt: now, InFlight: true}
+
+	o.mu.Lock()
+	o.state.Pending = append(o.state.Pending, entry)
+	saveErr := store.Save(o.path, o.state)
+	o.mu.Unlock()
+	if saveErr != nil {
+		// Without a saved copy nothing would retry it, so report the attempt as is
+		o.finish(entry, nil, now)
+		sentID, err := n.next.SendNotification(event)
+		if err != nil {
+			return sentID, errors.Join(err, fmt.Errorf("error saving notification to outbox: %w", saveErr))
+		}
+		// It was sent, so reporting the error would only get it sent again
+		log.Printf("Error saving notification to outbox: %v", saveErr)
+		return sentID, nil
+	}
+
+	sentID, sendErr := n.next.SendNotification(event)
+	dead, err := o.finish(entry, sendErr, now)
+	if err != nil {
+		// The outcome is still kept in memory, so only the next run may differ
+		log.Printf("Error saving outbox: %v", err)
+	}
+	if sendErr != nil && dead {
+		// Nothing will retry it
+		return "", sendErr
+	}
+	if sendErr != nil {
+		return "", &RetryError{Err: sendErr}
+	}
+	return sentID, nil
+}
+
+func newOutboxID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating outbox entry ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,262 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbox_RetriesUntilDelivered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	outbox, err := NewOutbox(path)
+	require.NoError(t, err)
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	outbox.now = func() time.Time { return start }
+	next := &recordingNotifier{id: "sent", err: errors.New("mailgun is down")}
+	notifier := outbox.Wrap("email", next)
+
+	_, err = notifier.SendNotification(testEvent())
+	require.Error(t, err)
+	assert.True(t, Queued(err))
+	assert.True(t, Queued(fmt.Errorf("email: %w", err)))
+	assert.False(t, Queued(errors.Join(err, errors.New("not queued"))))
+
+	pending := outbox.Pending()
+	require.Len(t, pending, 1)
+	assert.Equal(t, "email", pending[0].Channel)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "mailgun is down", pending[0].LastError)
+	assert.Equal(t, start.Add(time.Minute), pending[0].NextAttempt)
+
+	// Not due yet
+	require.NoError(t, outbox.Deliver(start.Add(30*time.Second)))
+	assert.Len(t, next.events, 1)
+
+	// The second failure doubles the backoff
+	require.Error(t, outbox.Deliver(start.Add(time.Minute)))
+	assert.Len(t, next.events, 2)
+	assert.Equal(t, start.Add(3*time.Minute), outbox.Pending()[0].NextAttempt)
+
+	// Entries survive a restart and are delivered to the channel with the same key
+	reopened, err := NewOutbox(path)
+	require.NoError(t, err)
+	recovered := &recordingNotifier{id: "sent"}
+	reopened.Wrap("email", recovered)
+	require.NoError(t, reopened.Deliver(start.Add(3*time.Minute)))
+	require.Len(t, recovered.events, 1)
+	assert.Equal(t, testEvent().WatchID, recovered.events[0].WatchID)
+	assert.Empty(t, reopened.Pending())
+	assert.Empty(t, reopened.Dead())
+}
+
+func TestOutbox_DeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	outbox, err := NewOutbox(path)
+	require.NoError(t, err)
+	outbox.MaxAttempts = 2
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	outbox.now = func() time.Time { return start }
+	next := &recordingNotifier{err: errors.New("invalid phone number")}
+	notifier := outbox.Wrap("sms:+15551234567", next)
+
+	_, err = notifier.SendNotification(testEvent())
+	require.Error(t, err)
+	require.Error(t, outbox.Deliver(start.Add(time.Hour)))
+	assert.Empty(t, outbox.Pending())
+	dead := outbox.Dead()
+	require.Len(t, dead, 1)
+	assert.Equal(t, 2, dead[0].Attempts)
+
+	// Dead letters are not retried on their own
+	require.NoError(t, outbox.Deliver(start.Add(24*time.Hour)))
+	assert.Len(t, next.events, 2)
+
+	assert.ErrorIs(t, outbox.Retry("unknown"), ErrNotDeadLetter)
+
+	next.err = nil
+	require.NoError(t, outbox.Retry(dead[0].ID))
+	assert.Empty(t, outbox.Dead())
+	require.NoError(t, outbox.Deliver(start))
+	assert.Len(t, next.events, 3)
+	assert.Empty(t, outbox.Pending())
+
+	reopened, err := NewOutbox(path)
+	require.NoError(t, err)
+	assert.Empty(t, reopened.Pending())
+	assert.Empty(t, reopened.Dead())
+}
+
+// sendFunc is a Notifier calling a function
+type sendFunc func(Event) (string, error)
+
+func (f sendFunc) SendNotification(event Event) (string, error) {
+	return f(event)
+}
+
+func TestOutbox_SkipsEntriesInFlight(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	outbox, err := NewOutbox(path)
+	require.NoError(t, err)
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	outbox.now = func() time.Time { return start }
+	attempts := 0
+	var afterCrash *Outbox
+	notifier := outbox.Wrap("webhook", sendFunc(func(Event) (string, error) {
+		attempts++
+		// However long the first attempt takes, Deliver doesn't send it again
+		require.NoError(t, outbox.Deliver(start.Add(time.Hour)))
+		require.Len(t, outbox.Pending(), 1)
+		assert.True(t, outbox.Pending()[0].InFlight)
+
+		// Had the process stopped here, the entry would be retried
+		var err error
+		afterCrash, err = NewOutbox(path)
+		require.NoError(t, err)
+		return "", errors.New("timeout")
+	}))
+
+	_, err = notifier.SendNotification(testEvent())
+	assert.True(t, Queued(err))
+	assert.Equal(t, 1, attempts)
+	require.Len(t, outbox.Pending(), 1)
+	assert.False(t, outbox.Pending()[0].InFlight)
+
+	require.Len(t, afterCrash.Pending(), 1)
+	assert.False(t, afterCrash.Pending()[0].InFlight)
+	recovered := &recordingNotifier{id: "sent"}
+	afterCrash.Wrap("webhook", recovered)
+	require.NoError(t, afterCrash.Deliver(start))
+	assert.Len(t, recovered.events, 1)
+}
+
+func TestOutbox_DeliverOne(t *testing.T) {
+	outbox, err := NewOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+	require.NoError(t, err)
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	outbox.now = func() time.Time { return start }
+	email := &recordingNotifier{err: errors.New("mailgun is down")}
+	sms := &recordingNotifier{err: errors.New("twilio is down")}
+	_, err = outbox.Wrap("email", email).SendNotification(testEvent())
+	require.Error(t, err)
+	_, err = outbox.Wrap("sms", sms).SendNotification(testEvent())
+	require.Error(t, err)
+
+	// Only the chosen entry is sent, before it is due, and only its outcome counts
+	sms.err = nil
+	var smsID string
+	for _, entry := range outbox.Pending() {
+		if entry.Channel == "sms" {
+			smsID = entry.ID
+		}
+	}
+	require.NoError(t, outbox.DeliverOne(smsID, start))
+	assert.Len(t, sms.events, 2)
+	assert.Len(t, email.events, 1)
+	require.Len(t, outbox.Pending(), 1)
+	assert.Equal(t, "email", outbox.Pending()[0].Channel)
+
+	assert.ErrorIs(t, outbox.DeliverOne(smsID, start), ErrNotPending)
+}
+
+func TestOutbox_SentDespiteSaveErrors(t *testing.T) {
+	dir := t.TempDir()
+	outbox, err := NewOutbox(filepath.Join(dir, "outbox.json"))
+	require.NoError(t, err)
+	// A file where the state directory should be makes every save fail
+	blocked := filepath.Join(dir, "blocked")
+	require.NoError(t, os.WriteFile(blocked, nil, 0o644))
+	outbox.path = filepath.Join(blocked, "outbox.json")
+	next := &recordingNotifier{id: "msg-1"}
+	notifier := outbox.Wrap("slack", next)
+
+	// A notification that was sent is reported as sent, or it would be sent again
+	id, err := notifier.SendNotification(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "msg-1", id)
+	assert.Empty(t, outbox.Pending())
+
+	next.err = errors.New("slack is down")
+	_, err = notifier.SendNotification(testEvent())
+	require.Error(t, err)
+	assert.False(t, Queued(err))
+}
+
+func TestOutbox_SingleAttemptIsNotQueued(t *testing.T) {
+	outbox, err := NewOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+	require.NoError(t, err)
+	outbox.MaxAttempts = 1
+
+	_, err = outbox.Wrap("sms", &recordingNotifier{err: errors.New("invalid number")}).SendNotification(testEvent())
+	require.EqualError(t, err, "invalid number")
+	assert.False(t, Queued(err))
+	assert.Empty(t, outbox.Pending())
+	assert.Len(t, outbox.Dead(), 1)
+}
+
+func TestOutbox_SendsStraightAway(t *testing.T) {
+	outbox, err := NewOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+	require.NoError(t, err)
+	next := &recordingNotifier{id: "msg-1"}
+
+	id, err := outbox.Wrap("slack", next).SendNotification(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "msg-1", id)
+	assert.Empty(t, outbox.Pending())
+}
+
+func TestQuietHours_LeavesQueuedToOutbox(t *testing.T) {
+	dir := t.TempDir()
+	outbox, err := NewOutbox(filepath.Join(dir, "outbox.json"))
+	require.NoError(t, err)
+	quiet, err := NewQuietHours(filepath.Join(dir, "quiet.json"))
+	require.NoError(t, err)
+	window, err := ParseQuietWindow("22:00-07:00")
+	require.NoError(t, err)
+	next := &recordingNotifier{err: errors.New("down")}
+	notifier := quiet.Wrap("sms", window, outbox.Wrap("sms", next))
+
+	night := time.Date(2025, 7, 1, 3, 0, 0, 0, window.Location)
+	quiet.now = func() time.Time { return night }
+	_, err = notifier.SendNotification(testEvent())
+	require.NoError(t, err)
+
+	// The failed send is now the outbox's to retry, so quiet hours let it go
+	require.NoError(t, quiet.Flush(night.Add(5*time.Hour)))
+	assert.Len(t, next.events, 1)
+	assert.Len(t, outbox.Pending(), 1)
+	require.NoError(t, quiet.Flush(night.Add(6*time.Hour)))
+	assert.Len(t, next.events, 1)
+}
+
+func TestOutbox_HoldsRetriesDuringQuietHours(t *testing.T) {
+	dir := t.TempDir()
+	outbox, err := NewOutbox(filepath.Join(dir, "outbox.json"))
+	require.NoError(t, err)
+	quiet, err := NewQuietHours(filepath.Join(dir, "quiet.json"))
+	require.NoError(t, err)
+	window, err := ParseQuietWindow("22:00-07:00")
+	require.NoError(t, err)
+	outbox.Hold = quiet.Holds
+	next := &recordingNotifier{err: errors.New("down")}
+	notifier := quiet.Wrap("sms", window, outbox.Wrap("sms", next))
+
+	evening := time.Date(2025, 7, 1, 21, 0, 0, 0, window.Location)
+	quiet.now = func() time.Time { return evening }
+	outbox.now = quiet.now
+	_, err = notifier.SendNotification(testEvent())
+	require.True(t, Queued(err))
+	next.err = nil
+
+	// The retry comes due at night and waits for the morning
+	require.NoError(t, outbox.Deliver(evening.Add(2*time.Hour)))
+	assert.Len(t, next.events, 1)
+	require.NoError(t, outbox.Deliver(evening.Add(10*time.Hour)))
+	assert.Len(t, next.events, 2)
+	assert.Empty(t, outbox.Pending())
+}
@@ -0,0 +1,191 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/BohdanMelnyk/bus-shulter-checker/store"
+)
+
+// QuietWindow is a daily period during which notifications are held, e.g.
+// 22:00 to 07:00. Start and End are times since midnight in Location; a
+// window whose end is before its start runs overnight.
+type QuietWindow struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// ParseQuietWindow parses "HH:MM-HH:MM", optionally followed by "@" and an
+// IANA time zone, e.g. "22:00-07:00@Europe/Berlin". Without a zone the
+// parks' time zone is used.
+func ParseQuietWindow(value string) (QuietWindow, error) {
+	span, zone, hasZone := strings.Cut(strings.TrimSpace(value), "@")
+	w := QuietWindow{Location: shuttle.ParkTimeZone}
+	if hasZone {
+		loc, err := time.LoadLocation(strings.TrimSpace(zone))
+		if err != nil {
+			return QuietWindow{}, fmt.Errorf("invalid time zone in %q: %w", value, err)
+		}
+		w.Location = loc
+	}
+	start, end, ok := strings.Cut(span, "-")
+	if !ok {
+		return QuietWindow{}, fmt.Errorf("expected HH:MM-HH:MM, got %q", value)
+	}
+	var err error
+	if w.Start, err = parseTimeOfDay(start); err != nil {
+		return QuietWindow{}, err
+	}
+	if w.End, err = parseTimeOfDay(end); err != nil {
+		return QuietWindow{}, err
+	}
+	if w.Start == w.End {
+		return QuietWindow{}, fmt.Errorf("quiet window %q is empty", value)
+	}
+	return w, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window
+func (w QuietWindow) Contains(t time.Time) bool {
+	local := t.In(w.Location)
+	tod := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	if w.Start < w.End {
+		return tod >= w.Start && tod < w.End
+	}
+	return tod >= w.Start || tod < w.End
+}
+
+// QuietHours holds notifications for recipients that are in their quiet
+// window and delivers them once it ends. Held notifications are saved to
+// disk, so they survive restarts.
+type QuietHours struct {
+	// UrgentWithin lets openings for dates this close through at any time;
+	// zero holds everything
+	UrgentWithin time.Duration
+
+	path      string
+	mu        sync.Mutex
+	held      map[string][]Event
+	notifiers map[string]*quietNotifier
+
+	// now returns the current time; replaced in tests
+	now func() time.Time
+}
+
+// NewQuietHours creates a QuietHours keeping held notifications at path
+func NewQuietHours(path string) (*QuietHours, error) {
+	q := &QuietHours{
+		UrgentWithin: 72 * time.Hour,
+		path:         path,
+		held:         make(map[string][]Event),
+		notifiers:    make(map[string]*quietNotifier),
+		now:          time.Now,
+	}
+	if err := store.Load(path, &q.held); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Wrap returns a notifier that sends through next outside window and holds
+// events inside it. key identifies the recipient across restarts.
+func (q *QuietHours) Wrap(key string, window QuietWindow, next Notifier) Notifier {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := &quietNotifier{quiet: q, key: key, window: window, next: next}
+	q.notifiers[key] = n
+	return n
+}
+
+// Flush delivers the held notifications of every recipient whose quiet
+// window has ended. Notifications that fail stay held, unless the outbox
+// took them for retry.
+func (q *QuietHours) Flush(now time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var errs []error
+	changed := false
+	for key, events := range q.held {
+		n, ok := q.notifiers[key]
+		if !ok {
+			// The recipient has no quiet hours any more, or was removed
+			errs = append(errs, fmt.Errorf("dropping %d held notification(s) for unknown recipient %s", len(events), key))
+			delete(q.held, key)
+			changed = true
+			continue
+		}
+		if n.window.Contains(now) {
+			continue
+		}
+		for len(events) > 0 {
+			if _, err := n.next.SendNotification(events[0]); err != nil && !Queued(err) {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				break
+			}
+			events = events[1:]
+			changed = true
+		}
+		if len(events) == 0 {
+			delete(q.held, key)
+		} else {
+			q.held[key] = events
+		}
+	}
+	if changed {
+		if err := store.Save(q.path, q.held); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Holds reports whether an event for the recipient key would be held at now
+func (q *QuietHours) Holds(key string, event Event, now time.Time) bool {
+	q.mu.Lock()
+	n, ok := q.notifiers[key]
+	q.mu.Unlock()
+	return ok && n.window.Contains(now) && !q.urgent(event, now)
+}
+
+// urgent reports whether an event may interrupt quiet hours
+func (q *QuietHours) urgent(event Event, now time.Time) bool {
+	return event.Kind == KindOpened && q.UrgentWithin > 0 && event.AvailableWithin(now, q.UrgentWithin)
+}
+
+// quietNotifier is the Notifier returned by QuietHours.Wrap
+type quietNotifier struct {
+	quiet  *QuietHours
+	key    string
+	window QuietWindow
+	next   Notifier
+}
+
+// SendNotification holds the event during quiet hours unless it is urgent
+func (n *quietNotifier) SendNotification(event Event) (string, error) {
+	now := n.quiet.now()
+	if !n.window.Contains(now) || n.quiet.urgent(event, now) {
+		return n.next.SendNotification(event)
+	}
+
+	n.quiet.mu.Lock()
+	defer n.quiet.mu.Unlock()
+	n.quiet.held[n.key] = append(n.quiet.held[n.key], event)
+	if err := store.Save(n.quiet.path, n.quiet.held); err != nil {
+		return "", fmt.Errorf("error holding notification for quiet hours: %w", err)
+	}
+	return "", nil
+}
@@ -0,0 +1,83 @@
+package notification
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuietWindow(t *testing.T) {
+	w, err := ParseQuietWindow("22:00-07:30@Europe/Berlin")
+	require.NoError(t, err)
+	assert.Equal(t, 22*time.Hour, w.Start)
+	assert.Equal(t, 7*time.Hour+30*time.Minute, w.End)
+	assert.Equal(t, "Europe/Berlin", w.Location.String())
+
+	berlin := w.Location
+	assert.True(t, w.Contains(time.Date(2025, 7, 1, 23, 0, 0, 0, berlin)))
+	assert.True(t, w.Contains(time.Date(2025, 7, 1, 7, 29, 0, 0, berlin)))
+	assert.False(t, w.Contains(time.Date(2025, 7, 1, 7, 30, 0, 0, berlin)))
+	// 21:30 UTC is 23:30 in Berlin in summer
+	assert.True(t, w.Contains(time.Date(2025, 7, 1, 21, 30, 0, 0, time.UTC)))
+
+	w, err = ParseQuietWindow("13:00-14:00")
+	require.NoError(t, err)
+	assert.Equal(t, shuttle.ParkTimeZone, w.Location)
+	assert.False(t, w.Contains(time.Date(2025, 7, 1, 12, 0, 0, 0, shuttle.ParkTimeZone)))
+
+	for _, bad := range []string{"22:00", "22:00-22:00", "25:00-07:00", "22:00-07:00@Mars/Base"} {
+		_, err := ParseQuietWindow(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quiet.json")
+	quiet, err := NewQuietHours(path)
+	require.NoError(t, err)
+	window, err := ParseQuietWindow("22:00-07:00")
+	require.NoError(t, err)
+	next := &recordingNotifier{id: "sent"}
+	notifier := quiet.Wrap("sms:+15551234567", window, next)
+
+	night := time.Date(2025, 7, 1, 3, 0, 0, 0, shuttle.ParkTimeZone)
+	quiet.now = func() time.Time { return night }
+
+	// Dates weeks away are held
+	event := testEvent()
+	id, err := notifier.SendNotification(event)
+	require.NoError(t, err)
+	assert.Empty(t, id)
+	assert.Empty(t, next.events)
+
+	// A date within UrgentWithin goes through anyway
+	urgent := testEvent()
+	urgent.Dates[0].Date = "2025-07-02"
+	id, err = notifier.SendNotification(urgent)
+	require.NoError(t, err)
+	assert.Equal(t, "sent", id)
+	require.Len(t, next.events, 1)
+
+	// Held events survive a restart and go out once the window ends
+	quiet, err = NewQuietHours(path)
+	require.NoError(t, err)
+	quiet.Wrap("sms:+15551234567", window, next)
+	require.NoError(t, quiet.Flush(night.Add(time.Hour)))
+	assert.Len(t, next.events, 1)
+
+	next.err = errors.New("carrier down")
+	morning := time.Date(2025, 7, 1, 7, 15, 0, 0, shuttle.ParkTimeZone)
+	assert.Error(t, quiet.Flush(morning))
+	next.err = nil
+	require.NoError(t, quiet.Flush(morning.Add(30*time.Minute)))
+	require.Len(t, next.events, 3)
+	assert.Equal(t, "2025-08-05", next.events[2].Dates[0].Date)
+
+	require.NoError(t, quiet.Flush(morning.Add(time.Hour)))
+	assert.Len(t, next.events, 3)
+}
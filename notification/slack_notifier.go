@@ -0,0 +1,178 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+)
+
+// SlackNotifier implements the Notifier interface by posting Block Kit
+// messages to a Slack incoming webhook
+type SlackNotifier struct {
+	WebhookURL string
+	// WatchWebhooks overrides WebhookURL for individual watches, keyed by watch ID
+	WatchWebhooks map[string]string
+	Client        *http.Client
+}
+
+// NewSlackNotifier creates a new SlackNotifier posting to the given webhook
+func NewSlackNotifier(webhookURL string, watchWebhooks map[string]string) *SlackNotifier {
+	return &SlackNotifier{
+		WebhookURL:    webhookURL,
+		WatchWebhooks: watchWebhooks,
+		Client:        &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type slackText struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+	// Elements holds slackButton values in actions blocks and slackText
+	// values in context blocks
+	Elements []any `json:"elements,omitempty"`
+}
+
+type slackButton struct {
+	Type  string    `json:"type"`
+	Text  slackText `json:"text"`
+	URL   string    `json:"url"`
+	Style string    `json:"style,omitempty"`
+}
+
+type slackMessage struct {
+	// Text is the fallback shown in notifications and by clients without Block Kit
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// webhookFor returns the webhook for the event's watch
+func (s *SlackNotifier) webhookFor(event Event) string {
+	if url, ok := s.WatchWebhooks[event.WatchID]; ok && url != "" {
+		return url
+	}
+	return s.WebhookURL
+}
+
+// SendNotification posts the event to Slack. Incoming webhooks don't return a
+// message ID, so the returned id is always empty. Events for watches without
+// a webhook, when there is no default webhook either, are skipped.
+func (s *SlackNotifier) SendNotification(event Event) (string, error) {
+	webhook := s.webhookFor(event)
+	if webhook == "" {
+		return "", nil
+	}
+
+	payload, err := json.Marshal(slackMessageFor(event))
+	if err != nil {
+		return "", fmt.Errorf("error marshaling Slack message: %w", err)
+	}
+
+	resp, err := s.Client.Post(webhook, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		// The webhook URL is a secret, keep it out of logs
+		return "", fmt.Errorf("error posting to Slack: %w", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code from Slack: %d, body: %s", resp.StatusCode, string(body))
+	}
+	return "", nil
+}
+
+// slackMessageFor lays the event out as a header, the location, a table of
+// dates and seats and a "Book now" button
+func slackMessageFor(event Event) slackMessage {
+	msg := slackMessage{Text: event.Subject()}
+	msg.Blocks = append(msg.Blocks, slackBlock{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: truncate(event.Subject(), 150), Emoji: true},
+	})
+
+	if event.Kind == KindAlert || event.Kind == KindDigest {
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: truncate(event.Message, 3000)},
+		})
+		return msg
+	}
+
+	if event.Location != "" {
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type: "section",
+			Fields: []slackText{
+				{Type: "mrkdwn", Text: "*Location*\n" + event.Location},
+				{Type: "mrkdwn", Text: "*Watch*\n" + event.Watch},
+			},
+		})
+	}
+
+	if len(event.Dates) > 0 {
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: "```\n" + seatTable(event.Dates) + "```"},
+		})
+	}
+
+	if event.BookingURL != "" && event.Kind != KindClosed {
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type: "actions",
+			Elements: []any{slackButton{
+				Type:  "button",
+				Text:  slackText{Type: "plain_text", Text: "Book now"},
+				URL:   event.BookingURL,
+				Style: "primary",
+			}},
+		})
+	}
+
+	if !event.DetectedAt.IsZero() {
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type: "context",
+			Elements: []any{slackText{
+				Type: "mrkdwn",
+				Text: "Detected " + event.DetectedAt.Format("Mon Jan 2 15:04 MST"),
+			}},
+		})
+	}
+	return msg
+}
+
+// seatTable renders one line per date and departure with the seat count,
+// aligned for a monospaced block
+func seatTable(dates []DateAvailability) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-10s  %-20s  %s\n", "Date", "Departure", "Seats")
+	for _, d := range dates {
+		if d.State != shuttle.StateAvailable {
+			fmt.Fprintf(&b, "%-10s  %-20s  %s\n", d.Date, "-", stateLabel(d.State))
+			continue
+		}
+		for _, dep := range d.Departures {
+			fmt.Fprintf(&b, "%-10s  %-20s  %d\n", d.Date, truncate(dep.Name(), 20), dep.Seats)
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, max int) string {
+	if len([]rune(s)) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
@@ -0,0 +1,73 @@
+package notification
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlackNotifier_SendNotification(t *testing.T) {
+	var received []map[string]any
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var msg map[string]any
+		require.NoError(t, json.Unmarshal(body, &msg))
+		received = append(received, msg)
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	notifier := NewSlackNotifier(server.URL+"/default", map[string]string{
+		"lake-ohara": server.URL + "/ohara",
+	})
+
+	event := testEvent()
+	event.WatchID = "moraine-morning"
+	_, err := notifier.SendNotification(event)
+	require.NoError(t, err)
+
+	event.WatchID = "lake-ohara"
+	_, err = notifier.SendNotification(event)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/default", "/ohara"}, paths)
+
+	msg := received[0]
+	assert.Equal(t, event.Subject(), msg["text"])
+	raw, _ := json.Marshal(msg["blocks"])
+	blocks := string(raw)
+	assert.Contains(t, blocks, `"type":"header"`)
+	assert.Contains(t, blocks, "Moraine Lake")
+	assert.Contains(t, blocks, "6:30 AM")
+	assert.Contains(t, blocks, `"url":"https://reservation.pc.gc.ca/create-booking/results?resourceLocationId=-2147483642"`)
+	assert.Contains(t, blocks, "Book now")
+}
+
+func TestSlackNotifier_SendNotificationError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no_service"))
+	}))
+	defer server.Close()
+
+	_, err := NewSlackNotifier(server.URL, nil).SendNotification(testEvent())
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "no_service"))
+}
+
+func TestSlackNotifier_HidesWebhookInErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	_, err := NewSlackNotifier(server.URL+"/services/T000/B000/secret", nil).SendNotification(testEvent())
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
@@ -0,0 +1,216 @@
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultSMSBaseURL is Twilio's REST API endpoint
+const DefaultSMSBaseURL = "https://api.twilio.com"
+
+// SMS length limits for a single segment. Messages with characters outside
+// ASCII are sent as UCS-2 by carriers and get the shorter limit.
+const (
+	smsSegmentLength        = 160
+	smsUnicodeSegmentLength = 70
+)
+
+// SMSNotifier implements the Notifier interface by sending text messages
+// through the Twilio Messages API or a compatible service
+type SMSNotifier struct {
+	BaseURL    string
+	AccountSID string
+	AuthToken  string
+	From       string
+	To         []string
+	// ShortLinkBase, when set, replaces the long booking URL with
+	// <ShortLinkBase>/go/<watch-id>, served by the checker itself
+	ShortLinkBase string
+	// MaxLength caps the message length in characters; zero means one segment
+	MaxLength int
+	Client    *http.Client
+}
+
+// NewSMSNotifier creates a new SMSNotifier. An empty baseURL uses DefaultSMSBaseURL.
+func NewSMSNotifier(baseURL, accountSID, authToken, from string, to []string) *SMSNotifier {
+	if baseURL == "" {
+		baseURL = DefaultSMSBaseURL
+	}
+	return &SMSNotifier{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		AccountSID: accountSID,
+		AuthToken:  authToken,
+		From:       from,
+		To:         to,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type smsResponse struct {
+	SID     string `json:"sid"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// SendNotification texts the event to every recipient and returns the message SIDs
+func (sn *SMSNotifier) SendNotification(event Event) (string, error) {
+	text := smsText(event, sn.link(event), sn.MaxLength)
+
+	var ids []string
+	var errs []error
+	for _, to := range sn.To {
+		sid, err := sn.send(to, text)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", to, err))
+			continue
+		}
+		ids = append(ids, sid)
+	}
+	return strings.Join(ids, ","), errors.Join(errs...)
+}
+
+// link returns the shortest booking link available for the event
+func (sn *SMSNotifier) link(event Event) string {
+	if sn.ShortLinkBase != "" && event.WatchID != "" {
+		return strings.TrimRight(sn.ShortLinkBase, "/") + "/go/" + url.PathEscape(event.WatchID)
+	}
+	return event.BookingURL
+}
+
+func (sn *SMSNotifier) send(to, text string) (string, error) {
+	form := url.Values{
+		"From": {sn.From},
+		"To":   {to},
+		"Body": {text},
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", sn.BaseURL, url.PathEscape(sn.AccountSID))
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(sn.AccountSID, sn.AuthToken)
+
+	resp, err := sn.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending SMS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %w", err)
+	}
+	var result smsResponse
+	jsonErr := json.Unmarshal(body, &result)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if jsonErr == nil && result.Message != "" {
+			return "", fmt.Errorf("message rejected (status %d, code %d): %s", resp.StatusCode, result.Code, result.Message)
+		}
+		return "", fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+	if jsonErr != nil {
+		return "", fmt.Errorf("error decoding response: %w", jsonErr)
+	}
+	return result.SID, nil
+}
+
+// smsText renders the event as a short message that fits in maxLen
+// characters, keeping the link intact and dropping dates that don't fit
+func smsText(event Event, link string, maxLen int) string {
+	var head string
+	var items []string
+	switch event.Kind {
+	case KindAlert:
+		head = event.Title + ": " + event.Message
+	case KindOpened:
+		head = event.Watch + " open:"
+		for _, d := range event.AvailableDates() {
+			items = append(items, fmt.Sprintf("%s (%d)", shortDate(d.Date), d.Seats()))
+		}
+	default:
+		head = event.Subject()
+	}
+
+	tail := ""
+	if link != "" && event.Kind != KindAlert {
+		tail = " " + link
+	}
+
+	limit := maxLen
+	if limit <= 0 {
+		limit = smsSegmentLength
+		if !isASCII(head + strings.Join(items, "") + tail) {
+			limit = smsUnicodeSegmentLength
+		}
+	}
+
+	budget := limit - utf8.RuneCountInString(tail)
+	more := func(n int) string { return fmt.Sprintf(" +%d more", n) }
+	// Keep room for the "+N more" note, so truncating the head never cuts it
+	reserve := 0
+	if len(items) > 0 {
+		reserve = utf8.RuneCountInString(more(len(items)))
+	}
+	if reserve > budget {
+		items, reserve = nil, 0
+	}
+	text := truncateRunes(head, budget-reserve)
+	for i, item := range items {
+		sep := " "
+		if i > 0 {
+			sep = ", "
+		}
+		note := ""
+		if rest := len(items) - i - 1; rest > 0 {
+			note = more(rest)
+		}
+		// Only add a date if the "+N more" note for the rest would still fit
+		if utf8.RuneCountInString(text+sep+item+note) > budget {
+			text += more(len(items) - i)
+			break
+		}
+		text += sep + item
+	}
+	return text + tail
+}
+
+// shortDate formats a YYYY-MM-DD date as e.g. "Aug 5"
+func shortDate(date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return t.Format("Jan 2")
+}
+
+func truncateRunes(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	// An ASCII ellipsis keeps the message eligible for the longer segment limit
+	runes := []rune(s)
+	if n <= 3 {
+		return string(runes[:n])
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package notification
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMSNotifier_SendNotification(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", r.URL.Path)
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "AC123", user)
+		assert.Equal(t, "secret", pass)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "+15550001111", r.PostForm.Get("From"))
+
+		if r.PostForm.Get("To") == "+15559999999" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 21211, "message": "The 'To' number is not a valid phone number."}`))
+			return
+		}
+		bodies = append(bodies, r.PostForm.Get("Body"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid": "SM` + strings.TrimPrefix(r.PostForm.Get("To"), "+") + `"}`))
+	}))
+	defer server.Close()
+
+	notifier := NewSMSNotifier(server.URL, "AC123", "secret", "+15550001111", []string{"+15552223333", "+15559999999"})
+	notifier.ShortLinkBase = "https://checker.example.com/"
+	event := testEvent()
+	event.WatchID = "moraine-morning"
+
+	id, err := notifier.SendNotification(event)
+	assert.Equal(t, "SM15552223333", id)
+	assert.ErrorContains(t, err, "code 21211")
+
+	require.Len(t, bodies, 1)
+	assert.Equal(t, "Lake Morain Morning open: Aug 5 (3) https://checker.example.com/go/moraine-morning", bodies[0])
+}
+
+func TestSMSText_FitsLength(t *testing.T) {
+	event := testEvent()
+	event.Dates = nil
+	for _, date := range []string{"2025-08-01", "2025-08-02", "2025-08-03", "2025-08-04", "2025-08-05", "2025-08-06"} {
+		event.Dates = append(event.Dates, DateAvailability{Date: date, State: "available", Departures: []Departure{{Seats: 4}}})
+	}
+	link := "https://checker.example.com/go/moraine-morning"
+
+	text := smsText(event, link, 100)
+	assert.LessOrEqual(t, utf8.RuneCountInString(text), 100)
+	assert.Equal(t, "Lake Morain Morning open: Aug 1 (4) +5 more https://checker.example.com/go/moraine-morning", text)
+
+	// The link survives even when the headline has to be cut
+	text = smsText(event, link, 60)
+	assert.LessOrEqual(t, utf8.RuneCountInString(text), 60)
+	assert.True(t, strings.HasSuffix(text, " "+link))
+	// ...and so does the "+N more" note
+	assert.Equal(t, "La... +6 more "+link, text)
+
+	// Non-ASCII text falls back to the shorter Unicode segment
+	event.Watch = "Lac Moraine – matin et après-midi, navette très demandée"
+	text = smsText(event, link, 0)
+	assert.LessOrEqual(t, utf8.RuneCountInString(text), smsUnicodeSegmentLength)
+	assert.True(t, strings.HasSuffix(text, link))
+}
@@ -0,0 +1,287 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPSecurity selects how the connection to the mail server is protected
+type SMTPSecurity string
+
+const (
+	// SMTPStartTLS connects in plain text and upgrades with STARTTLS, usually on port 587
+	SMTPStartTLS SMTPSecurity = "starttls"
+	// SMTPTLS connects over TLS from the start, usually on port 465
+	SMTPTLS SMTPSecurity = "tls"
+	// SMTPNone sends in plain text; only for local relays
+	SMTPNone SMTPSecurity = "none"
+)
+
+// ParseSMTPSecurity parses an SMTP_SECURITY value; blank means STARTTLS
+func ParseSMTPSecurity(value string) (SMTPSecurity, error) {
+	switch s := SMTPSecurity(strings.ToLower(strings.TrimSpace(value))); s {
+	case "":
+		return SMTPStartTLS, nil
+	case SMTPStartTLS, SMTPTLS, SMTPNone:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown SMTP security %q, expected starttls, tls or none", value)
+	}
+}
+
+// DefaultPort returns the conventional submission port for the security mode
+func (s SMTPSecurity) DefaultPort() int {
+	switch s {
+	case SMTPTLS:
+		return 465
+	case SMTPNone:
+		return 25
+	default:
+		return 587
+	}
+}
+
+// SMTPNotifier implements the Notifier interface by sending email through any
+// SMTP server, such as a company relay or Gmail with an app password
+type SMTPNotifier struct {
+	Host     string
+	Port     int
+	Security SMTPSecurity
+	// Username and Password enable AUTH PLAIN; leave blank for unauthenticated relays
+	Username string
+	Password string
+	From     string
+	To       []string
+	Timeout  time.Duration
+	// Templates renders the message; nil uses the built-in templates
+	Templates *Templates
+
+	// tlsConfig overrides the TLS settings; set in tests to trust a local certificate
+	tlsConfig *tls.Config
+}
+
+// NewSMTPNotifier creates a new SMTPNotifier; a zero port uses the default for security
+func NewSMTPNotifier(host string, port int, security SMTPSecurity, from string, to []string) *SMTPNotifier {
+	if port == 0 {
+		port = security.DefaultPort()
+	}
+	return &SMTPNotifier{
+		Host:     host,
+		Port:     port,
+		Security: security,
+		From:     from,
+		To:       to,
+		Timeout:  30 * time.Second,
+	}
+}
+
+// SendNotification emails the event to every recipient and returns its Message-ID
+func (sn *SMTPNotifier) SendNotification(event Event) (string, error) {
+	from, err := mail.ParseAddress(sn.From)
+	if err != nil {
+		return "", fmt.Errorf("invalid sender address %q: %w", sn.From, err)
+	}
+	var to []*mail.Address
+	for _, addr := range sn.To {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return "", fmt.Errorf("invalid recipient address %q: %w", addr, err)
+		}
+		to = append(to, parsed)
+	}
+	if len(to) == 0 {
+		return "", fmt.Errorf("no recipients configured")
+	}
+
+	messageID, err := newMessageID(from.Address)
+	if err != nil {
+		return "", err
+	}
+	rendered, err := sn.Templates.orBuiltin().Render("email", event)
+	if err != nil {
+		return "", err
+	}
+	msg, err := buildMessage(from, to, messageID, rendered, event)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := sn.dial()
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	if sn.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", sn.Username, sn.Password, sn.Host)); err != nil {
+			return "", fmt.Errorf("error authenticating with SMTP server: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return "", fmt.Errorf("error setting SMTP sender: %w", err)
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr.Address); err != nil {
+			return "", fmt.Errorf("error adding SMTP recipient %s: %w", addr.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return "", fmt.Errorf("error starting SMTP data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return "", fmt.Errorf("error writing SMTP message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("error sending SMTP message: %w", err)
+	}
+	if err := client.Quit(); err != nil {
+		return "", fmt.Errorf("error closing SMTP session: %w", err)
+	}
+	return messageID, nil
+}
+
+// RenderSubject returns the subject SendNotification uses for the event
+func (sn *SMTPNotifier) RenderSubject(event Event) (string, error) {
+	msg, err := sn.Templates.orBuiltin().Render("email", event)
+	return msg.Subject, err
+}
+
+// dial connects to the server and secures the session according to Security
+func (sn *SMTPNotifier) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(sn.Host, strconv.Itoa(sn.Port))
+	dialer := &net.Dialer{Timeout: sn.Timeout}
+	tlsConfig := sn.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: sn.Host}
+	}
+
+	var conn net.Conn
+	var err error
+	if sn.Security == SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to SMTP server: %w", err)
+	}
+	if sn.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(sn.Timeout))
+	}
+
+	client, err := smtp.NewClient(conn, sn.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error starting SMTP session: %w", err)
+	}
+	if sn.Security == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("error starting TLS: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// buildMessage renders an RFC 5322 message, multipart/alternative when the
+// rendered message has an HTML part, and with reply headers when the event
+// follows up on an earlier email
+func buildMessage(from *mail.Address, to []*mail.Address, messageID string, rendered Message, event Event) ([]byte, error) {
+	recipients := make([]string, len(to))
+	for i, addr := range to {
+		recipients[i] = addr.String()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(recipients, ", "))
+	subject, inReplyTo := emailReply(rendered.Subject, event)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID)
+	if inReplyTo != "" {
+		fmt.Fprintf(&buf, "In-Reply-To: %s\r\n", inReplyTo)
+		fmt.Fprintf(&buf, "References: %s\r\n", inReplyTo)
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if rendered.HTML == "" {
+		if err := writeQuotedPrintablePart(&buf, "text/plain", rendered.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	// Clients show the last part they can display, so HTML goes last
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", rendered.Text},
+		{"text/html", rendered.HTML},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType+"; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, fmt.Errorf("error creating message part: %w", err)
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("error finishing message: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintablePart writes a single-part body with its headers
+func writeQuotedPrintablePart(buf *bytes.Buffer, contentType, body string) error {
+	fmt.Fprintf(buf, "Content-Type: %s; charset=utf-8\r\n", contentType)
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	return writeQuotedPrintable(buf, body)
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return fmt.Errorf("error encoding message body: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return fmt.Errorf("error encoding message body: %w", err)
+	}
+	return nil
+}
+
+// newMessageID returns a random Message-ID in the sender's domain
+func newMessageID(sender string) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating message ID: %w", err)
+	}
+	domain := "localhost"
+	if _, d, ok := strings.Cut(sender, "@"); ok && d != "" {
+		domain = d
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
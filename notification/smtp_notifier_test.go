@@ -0,0 +1,246 @@
+package notification
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTPServer is a minimal in-process SMTP server that accepts one message per session
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+
+	auth     string
+	from     string
+	rcpts    []string
+	data     string
+	usedTLS  bool
+	sessions chan struct{}
+}
+
+func newFakeSMTPServer(t *testing.T, tlsConfig *tls.Config, implicitTLS bool) *fakeSMTPServer {
+	var l net.Listener
+	var err error
+	if implicitTLS {
+		l, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	} else {
+		l, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	require.NoError(t, err)
+	s := &fakeSMTPServer{listener: l, tlsConfig: tlsConfig, usedTLS: implicitTLS, sessions: make(chan struct{}, 1)}
+	t.Cleanup(func() { l.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer func() { s.sessions <- struct{}{} }()
+	defer func() { conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if s.tlsConfig != nil && !s.usedTLS {
+				reply("250-localhost")
+				reply("250-STARTTLS")
+			} else {
+				reply("250-localhost")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, s.usedTLS = tlsConn, bufio.NewReader(tlsConn), true
+		case "AUTH":
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			s.auth = string(decoded)
+			reply("235 authenticated")
+		case "MAIL":
+			s.from = arg
+			reply("250 OK")
+		case "RCPT":
+			s.rcpts = append(s.rcpts, arg)
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data = data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("500 unknown command")
+		}
+	}
+}
+
+// testTLSConfigs returns matching server and client TLS settings using the
+// certificate httptest generates for 127.0.0.1
+func testTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	rootCAs := srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	return srv.TLS, &tls.Config{RootCAs: rootCAs, ServerName: "127.0.0.1"}
+}
+
+func TestSMTPNotifier_SendNotificationStartTLS(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	server := newFakeSMTPServer(t, serverTLS, false)
+
+	notifier := NewSMTPNotifier("127.0.0.1", server.port(), SMTPStartTLS, "Shuttle Checker <alerts@example.com>", []string{"a@example.com", "b@example.com"})
+	notifier.Username = "alerts@example.com"
+	notifier.Password = "app-password"
+	notifier.tlsConfig = clientTLS
+
+	id, err := notifier.SendNotification(testEvent())
+	require.NoError(t, err)
+	<-server.sessions
+
+	assert.True(t, server.usedTLS)
+	assert.Equal(t, "\x00alerts@example.com\x00app-password", server.auth)
+	assert.Equal(t, "FROM:<alerts@example.com>", server.from)
+	assert.Equal(t, []string{"TO:<a@example.com>", "TO:<b@example.com>"}, server.rcpts)
+
+	msg, err := mail.ReadMessage(strings.NewReader(server.data))
+	require.NoError(t, err)
+	assert.Equal(t, id, msg.Header.Get("Message-ID"))
+	assert.True(t, strings.HasSuffix(id, "@example.com>"))
+	assert.Equal(t, testEvent().Subject(), msg.Header.Get("Subject"))
+	assert.Equal(t, `"Shuttle Checker" <alerts@example.com>`, msg.Header.Get("From"))
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "2025-08-05: available")
+}
+
+func TestSMTPNotifier_SendNotificationImplicitTLS(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	server := newFakeSMTPServer(t, serverTLS, true)
+
+	notifier := NewSMTPNotifier("127.0.0.1", server.port(), SMTPTLS, "alerts@example.com", []string{"a@example.com"})
+	notifier.tlsConfig = clientTLS
+
+	_, err := notifier.SendNotification(testEvent())
+	require.NoError(t, err)
+	<-server.sessions
+
+	assert.Empty(t, server.auth)
+	assert.Contains(t, server.data, "Subject: ")
+}
+
+func TestSMTPNotifier_SendsHTMLAlternative(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, false)
+	templates, err := LoadTemplates(writeTemplates(t, map[string]string{
+		"email.html.tmpl": "<h1>{{.Watch}}</h1>",
+	}))
+	require.NoError(t, err)
+
+	notifier := NewSMTPNotifier("127.0.0.1", server.port(), SMTPNone, "alerts@example.com", []string{"a@example.com"})
+	notifier.Templates = templates
+	_, err = notifier.SendNotification(testEvent())
+	require.NoError(t, err)
+	<-server.sessions
+
+	msg, err := mail.ReadMessage(strings.NewReader(server.data))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	var parts []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		parts = append(parts, part.Header.Get("Content-Type")+": "+string(body))
+	}
+	require.Len(t, parts, 2)
+	assert.True(t, strings.HasPrefix(parts[0], "text/plain; charset=utf-8: Shuttle slots are available"))
+	assert.Equal(t, "text/html; charset=utf-8: <h1>Lake Morain Morning</h1>", parts[1])
+}
+
+func TestSMTPNotifier_RepliesToThread(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, false)
+	notifier := NewSMTPNotifier("127.0.0.1", server.port(), SMTPNone, "alerts@example.com", []string{"a@example.com"})
+
+	event := testClosedEvent()
+	event.Thread = &Thread{MessageID: "<abc@example.com>", Subject: testEvent().Subject()}
+	_, err := notifier.SendNotification(event)
+	require.NoError(t, err)
+	<-server.sessions
+
+	msg, err := mail.ReadMessage(strings.NewReader(server.data))
+	require.NoError(t, err)
+	assert.Equal(t, "<abc@example.com>", msg.Header.Get("In-Reply-To"))
+	assert.Equal(t, "<abc@example.com>", msg.Header.Get("References"))
+	assert.Equal(t, "Re: "+testEvent().Subject(), msg.Header.Get("Subject"))
+}
+
+func TestSMTPNotifier_RequiresStartTLS(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, false)
+
+	notifier := NewSMTPNotifier("127.0.0.1", server.port(), SMTPStartTLS, "alerts@example.com", []string{"a@example.com"})
+	_, err := notifier.SendNotification(testEvent())
+	assert.ErrorContains(t, err, "STARTTLS")
+}
+
+func TestParseSMTPSecurity(t *testing.T) {
+	for value, want := range map[string]SMTPSecurity{"": SMTPStartTLS, "TLS": SMTPTLS, "none": SMTPNone} {
+		got, err := ParseSMTPSecurity(value)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseSMTPSecurity("ssl3")
+	assert.Error(t, err)
+	assert.Equal(t, 465, SMTPTLS.DefaultPort())
+	assert.Equal(t, 587, NewSMTPNotifier("smtp.example.com", 0, SMTPStartTLS, "", nil).Port)
+}
@@ -0,0 +1,163 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+)
+
+// DefaultTelegramBaseURL is the public Bot API endpoint
+const DefaultTelegramBaseURL = "https://api.telegram.org"
+
+// TelegramNotifier implements the Notifier interface by sending MarkdownV2
+// messages through the Telegram Bot API to one or more chats
+type TelegramNotifier struct {
+	Token   string
+	BaseURL string
+	ChatIDs []string
+	Client  *http.Client
+}
+
+// NewTelegramNotifier creates a new TelegramNotifier. An empty baseURL uses
+// DefaultTelegramBaseURL.
+func NewTelegramNotifier(token, baseURL string, chatIDs []string) *TelegramNotifier {
+	if baseURL == "" {
+		baseURL = DefaultTelegramBaseURL
+	}
+	return &TelegramNotifier{
+		Token:   token,
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		ChatIDs: chatIDs,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type telegramSendMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+	ReplyToMessageID      int64  `json:"reply_to_message_id,omitempty"`
+	// AllowSendingWithoutReply still sends a follow-up if the original was deleted
+	AllowSendingWithoutReply bool `json:"allow_sending_without_reply,omitempty"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Result      struct {
+		MessageID int64 `json:"message_id"`
+	} `json:"result"`
+}
+
+// SendNotification sends the event to every chat. The returned id lists the
+// sent messages as chat:message pairs; chats that failed are reported in err.
+// Follow-ups are sent as replies to the messages in event.Thread.
+func (t *TelegramNotifier) SendNotification(event Event) (string, error) {
+	text := telegramText(event)
+	replyTo := make(map[string]int64)
+	if event.Thread != nil {
+		for _, sent := range strings.Split(event.Thread.MessageID, ",") {
+			chatID, messageID, _ := strings.Cut(sent, ":")
+			if id, err := strconv.ParseInt(messageID, 10, 64); err == nil {
+				replyTo[chatID] = id
+			}
+		}
+	}
+
+	var ids []string
+	var errs []error
+	for _, chatID := range t.ChatIDs {
+		messageID, err := t.send(chatID, text, replyTo[chatID])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", chatID, err))
+			continue
+		}
+		ids = append(ids, fmt.Sprintf("%s:%d", chatID, messageID))
+	}
+	return strings.Join(ids, ","), errors.Join(errs...)
+}
+
+func (t *TelegramNotifier) send(chatID, text string, replyTo int64) (int64, error) {
+	payload, err := json.Marshal(telegramSendMessage{
+		ChatID:                   chatID,
+		Text:                     text,
+		ParseMode:                "MarkdownV2",
+		DisableWebPagePreview:    true,
+		ReplyToMessageID:         replyTo,
+		AllowSendingWithoutReply: replyTo != 0,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling Telegram message: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", t.BaseURL, t.Token)
+	resp, err := t.Client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		// The URL contains the bot token, keep it out of logs
+		return 0, fmt.Errorf("error calling Telegram Bot API: %w", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+
+	var result telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("error decoding Telegram response (status %d): %w", resp.StatusCode, err)
+	}
+	if !result.OK {
+		return 0, fmt.Errorf("message rejected by Telegram (status %d): %s", resp.StatusCode, result.Description)
+	}
+	return result.Result.MessageID, nil
+}
+
+// telegramText renders the event as MarkdownV2
+func telegramText(event Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n", escapeMarkdownV2(event.Subject()))
+
+	if event.Kind == KindAlert || event.Kind == KindDigest {
+		fmt.Fprintf(&b, "\n%s", escapeMarkdownV2(event.Message))
+		return b.String()
+	}
+
+	if event.Location != "" {
+		fmt.Fprintf(&b, "%s\n", escapeMarkdownV2(event.Location))
+	}
+	for _, date := range event.Dates {
+		fmt.Fprintf(&b, "\n*%s*: %s\n", escapeMarkdownV2(date.Date), escapeMarkdownV2(stateLabel(date.State)))
+		if date.State != shuttle.StateAvailable {
+			continue
+		}
+		for _, dep := range date.Departures {
+			if dep.Seats > 0 {
+				fmt.Fprintf(&b, "• %s: %s\n", escapeMarkdownV2(dep.Name()), escapeMarkdownV2(seatCount(dep.Seats)))
+			}
+		}
+	}
+	if event.BookingURL != "" && event.Kind != KindClosed {
+		fmt.Fprintf(&b, "\n[Book now](%s)", escapeMarkdownV2URL(event.BookingURL))
+	}
+	return b.String()
+}
+
+// markdownV2Replacer escapes every character MarkdownV2 reserves in text
+var markdownV2Replacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+func escapeMarkdownV2(s string) string {
+	return markdownV2Replacer.Replace(s)
+}
+
+// escapeMarkdownV2URL escapes the characters reserved inside a link target
+func escapeMarkdownV2URL(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ")", `\)`).Replace(s)
+}
@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelegramNotifier_SendNotification(t *testing.T) {
+	var sent []telegramSendMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bot123:secret/sendMessage", r.URL.Path)
+		var msg telegramSendMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		sent = append(sent, msg)
+
+		if msg.ChatID == "-100999" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d}}`, 100+len(sent))
+	}))
+	defer server.Close()
+
+	notifier := NewTelegramNotifier("123:secret", server.URL, []string{"42", "-100999", "43"})
+	id, err := notifier.SendNotification(testEvent())
+
+	assert.Equal(t, "42:101,43:103", id)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "chat not found")
+	assert.NotContains(t, err.Error(), "secret")
+
+	require.Len(t, sent, 3)
+	assert.Equal(t, "MarkdownV2", sent[0].ParseMode)
+	assert.Contains(t, sent[0].Text, `*Shuttle slots available for Lake Morain Morning on 2025\-08\-05*`)
+	assert.Contains(t, sent[0].Text, `• 6:30 AM: 2 seats`)
+	assert.Contains(t, sent[0].Text, `*2025\-08\-06*: not yet released`)
+	assert.Contains(t, sent[0].Text, `[Book now](https://reservation.pc.gc.ca/create-booking/results?resourceLocationId=-2147483642)`)
+}
+
+func TestTelegramNotifier_RepliesToThread(t *testing.T) {
+	var sent []telegramSendMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg telegramSendMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		sent = append(sent, msg)
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":200}}`)
+	}))
+	defer server.Close()
+
+	event := testClosedEvent()
+	event.Thread = &Thread{MessageID: "42:101,43:103"}
+	_, err := NewTelegramNotifier("123:secret", server.URL, []string{"42", "44"}).SendNotification(event)
+	require.NoError(t, err)
+
+	require.Len(t, sent, 2)
+	assert.Equal(t, int64(101), sent[0].ReplyToMessageID)
+	assert.True(t, sent[0].AllowSendingWithoutReply)
+	assert.Zero(t, sent[1].ReplyToMessageID)
+}
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	assert.Equal(t, `Lake O'Hara \(2 seats\)\! 1\.5 \- \[x\]`, escapeMarkdownV2(`Lake O'Hara (2 seats)! 1.5 - [x]`))
+}
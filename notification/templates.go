@@ -0,0 +1,369 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+)
+
+// defaultTemplates are the built-in templates; files in a template directory
+// override them
+//
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// templateParts are the parts of a message a template can render
+var templateParts = []string{"subject", "text", "html"}
+
+// untemplatedChannels lay their messages out themselves, so templates named
+// after them would never be used
+var untemplatedChannels = []string{"slack", "discord", "telegram", "sms", "webhook"}
+
+// templatedChannels are the channels that render templates
+var templatedChannels = []string{"email", "ntfy"}
+
+// templateFuncs are available to every template
+var templateFuncs = map[string]any{
+	"stateLabel": stateLabel,
+	"seatCount":  seatCount,
+	"duration":   duration,
+	"dateList":   dateList,
+	"join":       strings.Join,
+	"stateColor": stateColor,
+	"longDate":   longDate,
+	"parkTime":   parkTime,
+	"inc":        func(i int) int { return i + 1 },
+	"splitLines": func(s string) []string { return strings.Split(strings.TrimSpace(s), "\n\n") },
+	"departures": departures,
+	"departure":  departure,
+}
+
+// departures returns every departure found on any of the dates, once each,
+// in the order they first appear
+func departures(dates []DateAvailability) []Departure {
+	var all []Departure
+	seen := make(map[int64]bool)
+	for _, date := range dates {
+		for _, d := range date.Departures {
+			if !seen[d.ResourceID] {
+				seen[d.ResourceID] = true
+				all = append(all, d)
+			}
+		}
+	}
+	return all
+}
+
+// departure returns the date's departure with the given resource ID, or nil
+// if the date has none
+func departure(date DateAvailability, resourceID int64) *Departure {
+	for i := range date.Departures {
+		if date.Departures[i].ResourceID == resourceID {
+			return &date.Departures[i]
+		}
+	}
+	return nil
+}
+
+// stateColor returns the colour used for a slot state in HTML messages
+func stateColor(state shuttle.SlotState) string {
+	switch state {
+	case shuttle.StateAvailable:
+		return "#188038"
+	case shuttle.StateSoldOut:
+		return "#c5221f"
+	case shuttle.StateNotReleased:
+		return "#b06000"
+	default:
+		return "#5f6368"
+	}
+}
+
+// parkTime formats a time in the parks' time zone, e.g. "Aug 5 14:30"
+func parkTime(t time.Time) string {
+	return t.In(shuttle.ParkTimeZone).Format("Jan 2 15:04")
+}
+
+// longDate formats a YYYY-MM-DD date as e.g. "Tue, Aug 5"
+func longDate(date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return t.Format("Mon, Jan 2")
+}
+
+// builtinTemplates renders Event.Subject and Event.Text
+var builtinTemplates = mustLoadBuiltinTemplates()
+
+// Message is a notification rendered from templates
+type Message struct {
+	Subject string
+	Text    string
+	// HTML is empty when no HTML template applies
+	HTML string
+}
+
+// templateData is what text and HTML templates are executed with: the event,
+// plus the subject as rendered for the same channel
+type templateData struct {
+	Event
+	Subject string
+}
+
+// Templates renders notifications from Go templates. Each file is named
+// <name>.<part>.tmpl, where part is subject, text or html and name is one of,
+// from most to least specific:
+//
+//   - <channel>.<watch-id>, e.g. email.lake-ohara
+//   - <watch-id>
+//   - <channel>
+//   - default
+//
+// Only the email and ntfy channels render templates; names starting with
+// another channel, and names that match no watch, are rejected. Templates
+// are executed with the Event as data. Text and HTML templates can also use
+// .Subject, the subject rendered for the same channel.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates loads the built-in templates and the overrides in dir. Every
+// template is test-rendered, so mistakes are reported here rather than when
+// the first slot opens. An empty dir loads only the built-in templates.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	builtin, err := fs.Sub(defaultTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.load(builtin); err != nil {
+		return nil, fmt.Errorf("error loading built-in templates: %w", err)
+	}
+	if dir != "" {
+		if err := t.load(os.DirFS(dir)); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func mustLoadBuiltinTemplates() *Templates {
+	t, err := LoadTemplates("")
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t *Templates) load(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		base := strings.TrimSuffix(file, ".tmpl")
+		dot := strings.LastIndex(base, ".")
+		if dot <= 0 {
+			return fmt.Errorf("template %s: expected <name>.<part>.tmpl", file)
+		}
+		name, part := base[:dot], base[dot+1:]
+		channel, _, _ := strings.Cut(name, ".")
+		if slices.Contains(untemplatedChannels, channel) {
+			return fmt.Errorf("template %s: the %s channel doesn't use templates, only email and ntfy do", file, channel)
+		}
+		if !knownTemplateName(name) {
+			// A typo would leave the template unused without a word
+			return fmt.Errorf("template %s: %q is not default, a channel that uses templates or a watch ID", file, name)
+		}
+
+		src, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("error reading template %s: %w", file, err)
+		}
+		switch part {
+		case "subject", "text":
+			tmpl, err := texttemplate.New(file).Funcs(templateFuncs).Parse(string(src))
+			if err != nil {
+				return fmt.Errorf("error parsing template: %w", err)
+			}
+			t.text[name+"."+part] = tmpl
+		case "html":
+			tmpl, err := htmltemplate.New(file).Funcs(templateFuncs).Parse(string(src))
+			if err != nil {
+				return fmt.Errorf("error parsing template: %w", err)
+			}
+			t.html[name] = tmpl
+		default:
+			return fmt.Errorf("template %s: unknown part %q, expected one of %s", file, part, strings.Join(templateParts, ", "))
+		}
+	}
+	return nil
+}
+
+// knownTemplateName reports whether name is one of the names Render looks up
+// for some channel and watch
+func knownTemplateName(name string) bool {
+	channel, watchID, perWatch := strings.Cut(name, ".")
+	if perWatch {
+		return slices.Contains(templatedChannels, channel) && isWatchID(watchID)
+	}
+	return name == "default" || slices.Contains(templatedChannels, name) || isWatchID(name)
+}
+
+func isWatchID(id string) bool {
+	for _, location := range shuttle.Locations {
+		if location.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// validate renders every template against a sample of each kind of event,
+// with the same data Render uses
+func (t *Templates) validate() error {
+	for _, event := range sampleEvents() {
+		data := templateData{Event: event, Subject: "Subject"}
+		for key, tmpl := range t.text {
+			var input any = data
+			if strings.HasSuffix(key, ".subject") {
+				input = event
+			}
+			if err := tmpl.Execute(&bytes.Buffer{}, input); err != nil {
+				return fmt.Errorf("template %s.tmpl fails on a %s event: %w", key, event.Kind, err)
+			}
+		}
+		for name, tmpl := range t.html {
+			if err := tmpl.Execute(&bytes.Buffer{}, data); err != nil {
+				return fmt.Errorf("template %s.html.tmpl fails on a %s event: %w", name, event.Kind, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Render renders the event for a channel, using the most specific templates
+// for the channel and the event's watch
+func (t *Templates) Render(channel string, event Event) (Message, error) {
+	names := templateNames(channel, event.WatchID)
+
+	var msg Message
+	var err error
+	if msg.Subject, err = t.renderText(names, "subject", event); err != nil {
+		return Message{}, err
+	}
+	// Subjects are single-line headers
+	msg.Subject = strings.Join(strings.Fields(msg.Subject), " ")
+
+	data := templateData{Event: event, Subject: msg.Subject}
+	if msg.Text, err = t.renderText(names, "text", data); err != nil {
+		return Message{}, err
+	}
+	for _, name := range names {
+		if tmpl, ok := t.html[name]; ok {
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				return Message{}, fmt.Errorf("error rendering %s.html template: %w", name, err)
+			}
+			msg.HTML = buf.String()
+			break
+		}
+	}
+	return msg, nil
+}
+
+func (t *Templates) renderText(names []string, part string, data any) (string, error) {
+	for _, name := range names {
+		tmpl, ok := t.text[name+"."+part]
+		if !ok {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("error rendering %s.%s template: %w", name, part, err)
+		}
+		return buf.String(), nil
+	}
+	return "", fmt.Errorf("no %s template", part)
+}
+
+// templateNames lists template names from most to least specific
+func templateNames(channel, watchID string) []string {
+	var names []string
+	if channel != "" && watchID != "" {
+		names = append(names, channel+"."+watchID)
+	}
+	if watchID != "" {
+		names = append(names, watchID)
+	}
+	if channel != "" {
+		names = append(names, channel)
+	}
+	return append(names, "default")
+}
+
+// orBuiltin returns t, or the built-in templates when t is nil
+func (t *Templates) orBuiltin() *Templates {
+	if t == nil {
+		return builtinTemplates
+	}
+	return t
+}
+
+// sampleEvents returns one event of each kind for validating templates
+func sampleEvents() []Event {
+	opened := Event{
+		Kind:       KindOpened,
+		WatchID:    "lake-ohara",
+		Watch:      "Lake O'Hara",
+		Location:   "Lake O'Hara",
+		LocationID: -2147483643,
+		Dates: []DateAvailability{
+			{Date: "2025-08-05", State: shuttle.StateAvailable, Departures: []Departure{
+				{ResourceID: -2147476652, Label: "8:30 AM", State: shuttle.StateAvailable, Seats: 2},
+				{ResourceID: -2147476634, State: shuttle.StateSoldOut},
+			}},
+			{Date: "2025-08-06", State: shuttle.StateNotReleased},
+		},
+		BookingURL: "https://reservation.pc.gc.ca/create-booking/results?resourceLocationId=-2147483643",
+		DetectedAt: time.Date(2025, 7, 1, 8, 0, 0, 0, time.UTC),
+		Watching: []WatchSummary{
+			{WatchID: "moraine-morning", Watch: "Lake Morain Morning", Dates: []string{"2025-08-05"}, AvailableDates: []string{"2025-08-05"}, BookingURL: "https://reservation.pc.gc.ca/"},
+			{WatchID: "moraine-midday", Watch: "Lake Morain Midday", Dates: []string{"2025-08-05"}, Error: "not checked"},
+		},
+	}
+	opened.Dates[0].BookingURL = opened.BookingURL + "&startDate=2025-08-05&endDate=2025-08-05"
+	closed := opened
+	closed.Kind = KindClosed
+	closed.Dates = []DateAvailability{{Date: "2025-08-05", State: shuttle.StateSoldOut, Departures: []Departure{
+		{ResourceID: -2147476652, Label: "8:30 AM", State: shuttle.StateSoldOut, OpenFor: 12 * time.Minute},
+	}}}
+	closed.Thread = &Thread{MessageID: "<1@example.com>", Subject: "Shuttle slots available for Lake O'Hara on 2025-08-05"}
+	digest := Event{
+		Kind:       KindDigest,
+		WatchID:    opened.WatchID,
+		Watch:      opened.Watch,
+		Title:      "Daily shuttle digest for Lake O'Hara: 1 opening, 1 closing",
+		Message:    "Changes since the last digest: ...",
+		DetectedAt: opened.DetectedAt,
+		Digest:     []Event{opened, closed},
+	}
+	return []Event{opened, closed, digest, {Kind: KindAlert, Title: "Reservation API unavailable", Message: "Checks are paused."}}
+}
@@ -0,0 +1,115 @@
+package notification
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTemplates(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644))
+	}
+	return dir
+}
+
+func TestTemplates_Overrides(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"email.subject.tmpl":            "Email: {{.Watch}}",
+		"lake-ohara.subject.tmpl":       "O'Hara: {{len .AvailableDates}} date(s)",
+		"email.lake-ohara.subject.tmpl": "Email O'Hara:\n  {{dateList .AvailableDates}}",
+		"email.html.tmpl":               `<p>{{.Watch}}</p>{{range .Dates}}<b>{{.Date}}</b>{{end}}`,
+	})
+	templates, err := LoadTemplates(dir)
+	require.NoError(t, err)
+
+	event := testEvent()
+	msg, err := templates.Render("email", event)
+	require.NoError(t, err)
+	assert.Equal(t, "Email: Lake Morain Morning", msg.Subject)
+	// Parts without an override fall back to the built-in templates
+	assert.Equal(t, event.Text(), msg.Text)
+	assert.Equal(t, "<p>Lake Morain Morning</p><b>2025-08-05</b><b>2025-08-06</b>", msg.HTML)
+
+	msg, err = templates.Render("ntfy", event)
+	require.NoError(t, err)
+	assert.Equal(t, event.Subject(), msg.Subject)
+	assert.Empty(t, msg.HTML)
+
+	event.WatchID = "lake-ohara"
+	event.Watch = "<Lake O'Hara>"
+	msg, err = templates.Render("email", event)
+	require.NoError(t, err)
+	assert.Equal(t, "Email O'Hara: 2025-08-05", msg.Subject)
+	assert.Contains(t, msg.HTML, "<p>&lt;Lake O&#39;Hara&gt;</p>")
+
+	msg, err = templates.Render("ntfy", event)
+	require.NoError(t, err)
+	assert.Equal(t, "O'Hara: 1 date(s)", msg.Subject)
+}
+
+func TestLoadTemplates_RejectsBadTemplates(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"syntax":        {"email.subject.tmpl": "{{.Watch"},
+		"unknown field": {"email.text.tmpl": "{{.Seats}}"},
+		"unknown part":  {"email.body.tmpl": "hi"},
+		"slack":         {"slack.subject.tmpl": "hi"},
+		"sms per watch": {"sms.lake-ohara.text.tmpl": "hi"},
+		"html":          {"default.html.tmpl": "{{range .Dates}}{{.Nope}}{{end}}"},
+		// Subjects are rendered before there is a .Subject to wrap them in
+		"subject data":   {"email.subject.tmpl": "{{.Event.Watch}}"},
+		"watch typo":     {"lake-oharra.subject.tmpl": "hi"},
+		"per-watch typo": {"email.lake-oharra.text.tmpl": "hi"},
+		"unknown name":   {"emails.text.tmpl": "hi"},
+	} {
+		_, err := LoadTemplates(writeTemplates(t, files))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestBuiltinEmailHTML(t *testing.T) {
+	event := testEvent()
+	event.Dates[0].BookingURL = event.BookingURL + "&startDate=2025-08-05&endDate=2025-08-05"
+	event.Watching = []WatchSummary{
+		{Watch: "Lake O'Hara", Dates: []string{"2025-08-05"}, AvailableDates: []string{"2025-08-05"}, BookingURL: "https://example.com/ohara"},
+		{Watch: "Lake Morain Midday", Dates: []string{"2025-08-06"}},
+	}
+
+	msg, err := builtinTemplates.Render("email", event)
+	require.NoError(t, err)
+	assert.Equal(t, event.Text(), msg.Text)
+	assert.Contains(t, msg.HTML, "<title>Shuttle slots available for Lake Morain Morning on 2025-08-05</title>")
+	assert.Contains(t, msg.HTML, `<th align="center" style="padding:8px 4px;border-bottom:2px solid #dadce0;">6:30 AM</th>`)
+	assert.Contains(t, msg.HTML, `color:#188038;font-weight:bold;">2 seats</td>`)
+	assert.Contains(t, msg.HTML, `<a href="https://reservation.pc.gc.ca/create-booking/results?resourceLocationId=-2147483642&amp;startDate=2025-08-05&amp;endDate=2025-08-05"`)
+	assert.Equal(t, 1, strings.Count(msg.HTML, "Book&nbsp;now"), "only available dates get a button")
+	assert.Contains(t, msg.HTML, "Also watching")
+	assert.Contains(t, msg.HTML, "Open: 2025-08-05")
+	assert.Contains(t, msg.HTML, "Nothing open")
+
+	// Other channels don't get the email layout
+	msg, err = builtinTemplates.Render("ntfy", event)
+	require.NoError(t, err)
+	assert.Empty(t, msg.HTML)
+}
+
+func TestBuiltinEmailHTML_DepartureColumns(t *testing.T) {
+	event := testClosedEvent()
+	event.Dates = append(event.Dates, DateAvailability{Date: "2025-08-06", State: shuttle.StateSoldOut, Departures: []Departure{
+		{ResourceID: -2147476634, State: shuttle.StateSoldOut},
+		{ResourceID: -2147476600, Label: "4:30 PM", State: shuttle.StateSoldOut},
+	}})
+
+	msg, err := builtinTemplates.Render("email", event)
+	require.NoError(t, err)
+	header := `<th align="center" style="padding:8px 4px;border-bottom:2px solid #dadce0;">`
+	assert.Contains(t, msg.HTML, header+"6:30 AM</th>\n"+header+"Departure 2</th>\n"+header+"4:30 PM</th>")
+	// The first date has no 4:30 PM departure, and the second no 6:30 AM one
+	assert.Equal(t, 2, strings.Count(msg.HTML, `<td style="padding:8px 4px;border-bottom:1px solid #e8eaed;"></td>`))
+}
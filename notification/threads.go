@@ -0,0 +1,155 @@
+package notification
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/BohdanMelnyk/bus-shulter-checker/store"
+)
+
+// Threads remembers the last notification of every watch on each channel, so
+// that closed events can follow up on it: they are only sent to channels that
+// reported the slots that closed, as a reply where the channel supports it.
+// The message IDs are saved to disk, so follow-ups work across restarts.
+type Threads struct {
+	path string
+	mu   sync.Mutex
+	// threads holds the last message per channel key and watch ID
+	threads map[string]map[string]thread
+}
+
+// thread is a message closed events can follow up on
+type thread struct {
+	Thread
+	// Slots are the departures the message reported and no follow-up has
+	// covered yet, as date/resource ID
+	Slots []string `json:"slots"`
+}
+
+func slotKey(date string, resourceID int64) string {
+	return fmt.Sprintf("%s/%d", date, resourceID)
+}
+
+// NewThreads creates a Threads keeping message IDs at path
+func NewThreads(path string) (*Threads, error) {
+	t := &Threads{path: path, threads: make(map[string]map[string]thread)}
+	if err := store.Load(path, &t.threads); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Wrap returns a notifier that records the messages next sends for openings,
+// and sends closed events only if there is an opening to follow up on. key
+// names the channel across restarts.
+func (t *Threads) Wrap(key string, next Notifier) Notifier {
+	return &threadNotifier{threads: t, key: key, next: next}
+}
+
+// lookup returns the last message sent for a watch on a channel
+func (t *Threads) lookup(key, watchID string) (thread, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	th, ok := t.threads[key][watchID]
+	return th, ok
+}
+
+// record remembers the last message sent for a watch on a channel. If it
+// can't be saved, it is still kept in memory: the message itself was sent, and
+// reporting an error would only make the outbox send it again.
+func (t *Threads) record(key, watchID string, th thread) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.threads[key] == nil {
+		t.threads[key] = make(map[string]thread)
+	}
+	t.threads[key][watchID] = th
+	store.Save(t.path, t.threads)
+}
+
+// followedUp removes slots from the last message sent for a watch on a
+// channel, and forgets the message once all of its slots are followed up on
+func (t *Threads) followedUp(key, watchID string, slots []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	th, ok := t.threads[key][watchID]
+	if !ok {
+		return
+	}
+	th.Slots = slices.DeleteFunc(th.Slots, func(slot string) bool { return slices.Contains(slots, slot) })
+	if len(th.Slots) == 0 {
+		delete(t.threads[key], watchID)
+	} else {
+		t.threads[key][watchID] = th
+	}
+	store.Save(t.path, t.threads)
+}
+
+// threadNotifier is the Notifier returned by Threads.Wrap
+type threadNotifier struct {
+	threads *Threads
+	key     string
+	next    Notifier
+}
+
+// SendNotification sends closed events as a reply to the watch's last
+// opening on this channel, limited to the slots that opening reported. They
+// are skipped if it reported none of them.
+func (n *threadNotifier) SendNotification(event Event) (string, error) {
+	if event.Kind == KindClosed {
+		th, ok := n.threads.lookup(n.key, event.WatchID)
+		if !ok {
+			return "", nil
+		}
+		var dates []DateAvailability
+		var slots []string
+		for _, date := range event.Dates {
+			covered := date
+			covered.Departures = nil
+			for _, dep := range date.Departures {
+				if slot := slotKey(date.Date, dep.ResourceID); slices.Contains(th.Slots, slot) {
+					covered.Departures = append(covered.Departures, dep)
+					slots = append(slots, slot)
+				}
+			}
+			if len(covered.Departures) > 0 {
+				dates = append(dates, covered)
+			}
+		}
+		if len(slots) == 0 {
+			return "", nil
+		}
+		event.Dates = dates
+		event.Thread = &th.Thread
+		id, err := n.next.SendNotification(event)
+		if err == nil {
+			n.threads.followedUp(n.key, event.WatchID, slots)
+		}
+		return id, err
+	}
+
+	id, err := n.next.SendNotification(event)
+	if err != nil || event.Kind != KindOpened || event.WatchID == "" {
+		return id, err
+	}
+	// Channels without message IDs are still recorded, so the follow-up goes
+	// to the same place even if it can't be a reply
+	th := thread{Thread: Thread{MessageID: id, Subject: event.Subject()}}
+	if renderer, ok := n.next.(subjectRenderer); ok {
+		// Templates may give the channel its own subject
+		if subject, err := renderer.RenderSubject(event); err == nil {
+			th.Subject = subject
+		}
+	}
+	for _, date := range event.Dates {
+		for _, dep := range date.Departures {
+			if dep.State == shuttle.StateAvailable {
+				th.Slots = append(th.Slots, slotKey(date.Date, dep.ResourceID))
+			}
+		}
+	}
+	n.threads.record(n.key, event.WatchID, th)
+	return id, nil
+}
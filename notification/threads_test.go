@@ -0,0 +1,135 @@
+package notification
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testClosedEvent() Event {
+	event := testEvent()
+	event.Kind = KindClosed
+	event.Dates = []DateAvailability{{Date: "2025-08-05", State: shuttle.StateSoldOut, Departures: []Departure{
+		{ResourceID: -2147476652, Label: "6:30 AM", State: shuttle.StateSoldOut, OpenFor: 12 * time.Minute},
+		{ResourceID: -2147476634, State: shuttle.StateSoldOut, OpenFor: 95 * time.Minute},
+	}}}
+	return event
+}
+
+func TestThreads_FollowUps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "threads.json")
+	threads, err := NewThreads(path)
+	require.NoError(t, err)
+	telegram := &recordingNotifier{id: "42:101"}
+	sms := &recordingNotifier{id: "SM1"}
+	newFanout := func() *FanoutNotifier {
+		fanout, err := NewFanoutNotifier([]Channel{
+			{Name: "telegram", Recipient: "42", Notifier: threads.Wrap("telegram:42", telegram)},
+			{Name: "sms", Notifier: threads.Wrap("sms", sms)},
+		}, []Route{{WatchID: "moraine-morning", Channels: []string{"telegram"}}})
+		require.NoError(t, err)
+		return fanout
+	}
+	fanout := newFanout()
+
+	opened := testEvent()
+	opened.WatchID = "moraine-morning"
+	_, err = fanout.SendNotification(opened)
+	require.NoError(t, err)
+	require.Len(t, telegram.events, 1)
+	assert.Empty(t, sms.events)
+
+	// Threads survive a restart
+	threads, err = NewThreads(path)
+	require.NoError(t, err)
+	fanout = newFanout()
+
+	// The follow-up ignores routes, but only goes where the opening went, and
+	// only about the slots that closed
+	closed := testClosedEvent()
+	closed.WatchID = "moraine-morning"
+	first := closed
+	first.Dates = []DateAvailability{{Date: "2025-08-05", State: shuttle.StateAvailable, Departures: closed.Dates[0].Departures[:1]}}
+	_, err = fanout.SendNotification(first)
+	require.NoError(t, err)
+	assert.Empty(t, sms.events)
+	require.Len(t, telegram.events, 2)
+	assert.Equal(t, &Thread{MessageID: "42:101", Subject: opened.Subject()}, telegram.events[1].Thread)
+
+	// Slots already followed up on are left out of later follow-ups
+	_, err = fanout.SendNotification(closed)
+	require.NoError(t, err)
+	require.Len(t, telegram.events, 3)
+	require.Len(t, telegram.events[2].Dates, 1)
+	require.Len(t, telegram.events[2].Dates[0].Departures, 1)
+	assert.Equal(t, int64(-2147476634), telegram.events[2].Dates[0].Departures[0].ResourceID)
+
+	// Once every slot is followed up on, the thread is forgotten, so a later
+	// opening routed elsewhere doesn't get follow-ups here
+	_, err = fanout.SendNotification(closed)
+	require.NoError(t, err)
+	assert.Len(t, telegram.events, 3)
+	reopened, err := NewThreads(path)
+	require.NoError(t, err)
+	_, ok := reopened.lookup("telegram:42", "moraine-morning")
+	assert.False(t, ok)
+}
+
+// subjectNotifier is a recordingNotifier whose messages have a subject
+type subjectNotifier struct {
+	recordingNotifier
+	templates *Templates
+}
+
+func (n *subjectNotifier) RenderSubject(event Event) (string, error) {
+	msg, err := n.templates.Render("email", event)
+	return msg.Subject, err
+}
+
+func TestThreads_RecordsRenderedSubject(t *testing.T) {
+	templates, err := LoadTemplates(writeTemplates(t, map[string]string{"email.subject.tmpl": "Go book {{.Watch}}"}))
+	require.NoError(t, err)
+	threads, err := NewThreads(filepath.Join(t.TempDir(), "threads.json"))
+	require.NoError(t, err)
+	email := &subjectNotifier{recordingNotifier: recordingNotifier{id: "<1@example.com>"}, templates: templates}
+	notifier := threads.Wrap("email", email)
+
+	opened := testEvent()
+	opened.WatchID = "moraine-morning"
+	_, err = notifier.SendNotification(opened)
+	require.NoError(t, err)
+	closed := testClosedEvent()
+	closed.WatchID = "moraine-morning"
+	_, err = notifier.SendNotification(closed)
+	require.NoError(t, err)
+
+	require.Len(t, email.events, 2)
+	assert.Equal(t, &Thread{MessageID: "<1@example.com>", Subject: "Go book Lake Morain Morning"}, email.events[1].Thread)
+	subject, _ := emailReply("Shuttle slots gone", email.events[1])
+	assert.Equal(t, "Re: Go book Lake Morain Morning", subject)
+
+	// The email channels report the subject they send
+	assert.Implements(t, (*subjectRenderer)(nil), &EmailNotifier{})
+	assert.Implements(t, (*subjectRenderer)(nil), &SMTPNotifier{})
+}
+
+func TestClosedEventText(t *testing.T) {
+	closed := testClosedEvent()
+	assert.Equal(t, "Shuttle slots gone for Lake Morain Morning after 1 hour 35 minutes", closed.Subject())
+	text := closed.Text()
+	assert.True(t, strings.HasPrefix(text, "Previously reported shuttle slots for Lake Morain Morning are no longer available. They stayed open for 1 hour 35 minutes."))
+	assert.Contains(t, text, "  6:30 AM: gone after 12 minutes")
+}
+
+func TestDuration(t *testing.T) {
+	assert.Equal(t, "less than a minute", duration(20*time.Second))
+	assert.Equal(t, "1 minute", duration(time.Minute))
+	assert.Equal(t, "2 hours", duration(2*time.Hour))
+	assert.Equal(t, "1 hour 5 minutes", duration(65*time.Minute))
+	assert.Equal(t, "2 days 3 hours", duration(51*time.Hour+10*time.Minute))
+}
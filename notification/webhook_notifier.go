@@ -0,0 +1,210 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookVersion is the version of the webhook payload schema
+const WebhookVersion = "1"
+
+// Headers set on every webhook request
+const (
+	WebhookSignatureHeader   = "X-Shuttle-Signature"
+	WebhookTimestampHeader   = "X-Shuttle-Timestamp"
+	WebhookEventHeader       = "X-Shuttle-Event"
+	WebhookIdempotencyHeader = "Idempotency-Key"
+)
+
+// webhookSignaturePrefix tags the signature scheme, leaving room to rotate it
+const webhookSignaturePrefix = "v1="
+
+// WebhookPayload is the JSON body POSTed to webhook receivers
+type WebhookPayload struct {
+	Version string `json:"version"`
+	// ID is unique per event and repeated on retries, so receivers can drop duplicates
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	Event     Event     `json:"event"`
+}
+
+// WebhookNotifier implements the Notifier interface by POSTing a signed JSON
+// payload to each configured URL
+type WebhookNotifier struct {
+	URLs   []string
+	Secret string
+	// MaxRetries is how many times a failed delivery to a URL is retried
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry; it doubles each time
+	RetryBackoff time.Duration
+	Client       *http.Client
+
+	// sleep waits between retries; replaced in tests
+	sleep func(time.Duration)
+}
+
+// NewWebhookNotifier creates a new WebhookNotifier signing with secret
+func NewWebhookNotifier(urls []string, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URLs:         urls,
+		Secret:       secret,
+		MaxRetries:   3,
+		RetryBackoff: 2 * time.Second,
+		Client:       &http.Client{Timeout: 30 * time.Second},
+		sleep:        time.Sleep,
+	}
+}
+
+// SendNotification delivers the event to every URL and returns its idempotency
+// key. The key is derived from the event, so sending the same event again,
+// e.g. from the outbox, repeats it.
+func (wn *WebhookNotifier) SendNotification(event Event) (string, error) {
+	id, err := idempotencyKey(event)
+	if err != nil {
+		return "", err
+	}
+	payload := WebhookPayload{
+		Version:   WebhookVersion,
+		ID:        id,
+		Type:      "availability." + string(event.Kind),
+		CreatedAt: time.Now().UTC(),
+		Event:     event,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling webhook payload: %w", err)
+	}
+
+	var errs []error
+	for i, url := range wn.URLs {
+		if err := wn.deliver(url, payload, body); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", webhookTarget(i, url), err))
+		}
+	}
+	return id, errors.Join(errs...)
+}
+
+// webhookTarget names a receiver in errors by its position and host, as
+// receiver URLs often carry tokens
+func webhookTarget(i int, rawURL string) string {
+	if u, err := neturl.Parse(rawURL); err == nil && u.Host != "" {
+		return fmt.Sprintf("webhook %d (%s)", i+1, u.Host)
+	}
+	return fmt.Sprintf("webhook %d", i+1)
+}
+
+// deliver posts body to url, retrying with exponential backoff on network
+// errors, 429 and 5xx responses
+func (wn *WebhookNotifier) deliver(url string, payload WebhookPayload, body []byte) error {
+	backoff := wn.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := wn.post(url, payload, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= wn.MaxRetries {
+			return err
+		}
+		wn.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (wn *WebhookNotifier) post(url string, payload WebhookPayload, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		// Errors about the URL quote it, keep it out of logs
+		return false, fmt.Errorf("error creating request: %w", errors.Unwrap(err))
+	}
+
+	// Sign each attempt afresh so retries aren't rejected as stale
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(wn.Secret, timestamp, body))
+	req.Header.Set(WebhookEventHeader, payload.Type)
+	req.Header.Set(WebhookIdempotencyHeader, payload.ID)
+
+	resp, err := wn.Client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("error posting webhook: %w", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(respBody))
+}
+
+// idempotencyKey identifies an event by its kind, watch, detection time and
+// title. Events without a detection time get a random key.
+func idempotencyKey(event Event) (string, error) {
+	if event.DetectedAt.IsZero() {
+		return newIdempotencyKey()
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		string(event.Kind),
+		event.WatchID,
+		event.DetectedAt.UTC().Format(time.RFC3339Nano),
+		strconv.FormatBool(event.Reminder),
+		event.Title,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating idempotency key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SignWebhook computes the signature header value for a webhook body:
+// "v1=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the signature of a received webhook. Receivers pass the
+// values of the X-Shuttle-Timestamp and X-Shuttle-Signature headers and the
+// raw request body. Requests signed more than tolerance ago, or in the future
+// by more than tolerance, are rejected to stop replays.
+func VerifyWebhook(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp %q", timestamp)
+	}
+	age := time.Since(time.Unix(unix, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("webhook timestamp outside tolerance: %s", age.Round(time.Second))
+	}
+	if !strings.HasPrefix(signature, webhookSignaturePrefix) {
+		return errors.New("unsupported webhook signature scheme")
+	}
+	expected := SignWebhook(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("webhook signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package notification
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier_SendNotification(t *testing.T) {
+	var attempts int
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, VerifyWebhook("s3cret", r.Header.Get(WebhookTimestampHeader), r.Header.Get(WebhookSignatureHeader), body, 5*time.Minute))
+		keys = append(keys, r.Header.Get(WebhookIdempotencyHeader))
+
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload WebhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, WebhookVersion, payload.Version)
+		assert.Equal(t, "availability.opened", payload.Type)
+		assert.Equal(t, "availability.opened", r.Header.Get(WebhookEventHeader))
+		assert.Equal(t, testEvent().Watch, payload.Event.Watch)
+		assert.Equal(t, 2, payload.Event.Dates[0].Departures[0].Seats)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	var slept []time.Duration
+	notifier := NewWebhookNotifier([]string{server.URL}, "s3cret")
+	notifier.RetryBackoff = time.Second
+	notifier.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	id, err := notifier.SendNotification(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, slept)
+	// The idempotency key stays the same across retries
+	assert.Equal(t, []string{id, id, id}, keys)
+}
+
+func TestWebhookNotifier_IdempotencyKeyFollowsEvent(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(WebhookIdempotencyHeader))
+	}))
+	defer server.Close()
+	notifier := NewWebhookNotifier([]string{server.URL}, "s3cret")
+
+	event := testEvent()
+	event.WatchID = "moraine-morning"
+	event.DetectedAt = time.Date(2025, 7, 1, 8, 0, 0, 123, time.UTC)
+	// A retry sends the event again, possibly after a round trip through the outbox file
+	var saved Event
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &saved))
+	later := event
+	later.DetectedAt = later.DetectedAt.Add(5 * time.Minute)
+
+	for _, e := range []Event{event, saved, later} {
+		_, err := notifier.SendNotification(e)
+		require.NoError(t, err)
+	}
+	require.Len(t, keys, 3)
+	assert.Equal(t, keys[0], keys[1])
+	assert.NotEqual(t, keys[0], keys[2])
+}
+
+func TestWebhookNotifier_DoesNotRetryClientErrors(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier([]string{server.URL}, "s3cret")
+	notifier.sleep = func(time.Duration) {}
+
+	_, err := notifier.SendNotification(testEvent())
+	require.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestWebhookNotifier_HidesURLsInErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	notifier := NewWebhookNotifier([]string{server.URL + "/hooks/secret-path?token=secret-token"}, "s3cret")
+	notifier.MaxRetries = 0
+	_, err := notifier.SendNotification(testEvent())
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+	assert.Contains(t, err.Error(), "webhook 1 ("+strings.TrimPrefix(server.URL, "http://")+")")
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"version":"1"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	assert.NoError(t, VerifyWebhook("s3cret", now, SignWebhook("s3cret", now, body), body, time.Minute))
+	assert.Error(t, VerifyWebhook("other", now, SignWebhook("s3cret", now, body), body, time.Minute))
+	assert.Error(t, VerifyWebhook("s3cret", now, SignWebhook("s3cret", now, body), []byte(`{"version":"2"}`), time.Minute))
+	assert.Error(t, VerifyWebhook("s3cret", stale, SignWebhook("s3cret", stale, body), body, time.Minute))
+}
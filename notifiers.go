@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseWatchMap parses "watch-id=value" pairs separated by commas, as used by
+// per-watch settings such as SLACK_WATCH_WEBHOOKS
+func parseWatchMap(value string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("expected watch-id=value, got %q", pair)
+		}
+		m[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return m, nil
+}
+
+// splitList splits a comma-separated setting, dropping blank entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
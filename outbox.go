@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/notification"
+)
+
+// outboxPath returns where undelivered notifications are kept
+func outboxPath() string {
+	return filepath.Join(stateDirectory(), "outbox.json")
+}
+
+type outboxResponse struct {
+	Pending []notification.OutboxEntry `json:"pending"`
+	Dead    []notification.OutboxEntry `json:"dead"`
+}
+
+// outboxHandler lists the notifications waiting for a retry and the dead letters
+func outboxHandler(w http.ResponseWriter, r *http.Request, outbox *notification.Outbox) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(outboxResponse{Pending: outbox.Pending(), Dead: outbox.Dead()})
+}
+
+// outboxRetryHandler puts a dead letter back in the outbox and tries to
+// deliver it straight away. The status reflects that one attempt only.
+func outboxRetryHandler(w http.ResponseWriter, r *http.Request, outbox *notification.Outbox) {
+	err := outbox.Retry(r.PathValue("id"))
+	if errors.Is(err, notification.ErrNotDeadLetter) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = outbox.DeliverOne(r.PathValue("id"), time.Now())
+	if errors.Is(err, notification.ErrNotPending) {
+		// The background delivery picked it up first
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireToken lets requests through to next only if they carry token as a
+// bearer token
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// outboxCommand implements "main outbox [list | retry <id>... | retry all]".
+// It works on the saved outbox, so retried notifications are sent by the
+// next run of the checker.
+func outboxCommand(args []string, stdout io.Writer) error {
+	outbox, err := notification.NewOutbox(outboxPath())
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 || args[0] == "list" {
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSTATUS\tCHANNEL\tEVENT\tCREATED\tATTEMPTS\tLAST ERROR")
+		for _, list := range []struct {
+			status  string
+			entries []notification.OutboxEntry
+		}{{"pending", outbox.Pending()}, {"dead", outbox.Dead()}} {
+			for _, entry := range list.entries {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", entry.ID, list.status, entry.Channel,
+					entry.Event.Subject(), entry.CreatedAt.Format(time.RFC3339), entry.Attempts, entry.LastError)
+			}
+		}
+		return tw.Flush()
+	}
+
+	if args[0] != "retry" || len(args) < 2 {
+		return errors.New("usage: outbox [list | retry <id>... | retry all]")
+	}
+	ids := args[1:]
+	if ids[0] == "all" {
+		ids = nil
+		for _, entry := range outbox.Dead() {
+			ids = append(ids, entry.ID)
+		}
+	}
+	for _, id := range ids {
+		if err := outbox.Retry(id); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Queued %s for the next run\n", id)
+	}
+	return nil
+}
+
+// stateDirectory returns STATE_DIR, where state is kept between runs
+func stateDirectory() string {
+	if dir := os.Getenv("STATE_DIR"); dir != "" {
+		return dir
+	}
+	return "state"
+}
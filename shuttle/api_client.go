@@ -1,24 +1,30 @@
 package shuttle
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"strings"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
 	"time"
 )
 
+const reservationBaseURL = "https://reservation.pc.gc.ca"
+
 type AvailabilityRange struct {
-	Start time.Time `json:"start"`
-	End   time.Time `json:"end"`
+	Start DateTime `json:"start"`
+	End   DateTime `json:"end"`
 }
 
 type AvailabilityResult struct {
 	ResultCode              int       `json:"resultCode"`
 	ResourceID             int       `json:"resourceId"`
-	StartDate              string    `json:"startDate"`
-	EndDate                string    `json:"endDate"`
+	StartDate              DateTime  `json:"startDate"`
+	EndDate                DateTime  `json:"endDate"`
 	RemainingReservableQuota float64  `json:"remainingReservableQuota"`
 	RemainingTotalQuota     float64  `json:"remainingTotalQuota"`
 	ClosedQuota            float64  `json:"closedQuota"`
@@ -33,180 +39,344 @@ type ResourceAvailability struct {
 
 type APIClient struct {
 	client *http.Client
+
+	// warmUp makes the client load the reservation site's landing page before
+	// talking to the API, so it carries the same session cookies as a browser
+	warmUp   bool
+	warmMu   sync.Mutex
+	warmedUp bool
+
+	// headers overrides the default header profile; blank fields use defaults
+	headers HeaderProfile
+
+	// discoverVersion keeps the app-version header in line with the deployed web app
+	discoverVersion  bool
+	versionMu        sync.Mutex
+	appVersion       string
+	versionCheckedAt time.Time
+
+	// cache holds recent answers; nil disables caching
+	cache *responseCache
+
+	// pool routes requests through proxies; nil sends everything through client
+	proxies       []*url.URL
+	proxyStrategy ProxyStrategy
+	pool          *proxyPool
+
+	// breaker skips requests while the API is down; nil disables it
+	breaker *CircuitBreaker
+
+	// maxWindowDays caps the date range of a single request made by
+	// CheckLocation; zero means no limit
+	maxWindowDays int
+}
+
+// Option customises an APIClient created by NewAPIClient
+type Option func(*APIClient)
+
+// WithWarmUp enables loading the reservation site's landing page before the
+// first API request and again after a challenge, to pick up session cookies
+func WithWarmUp() Option {
+	return func(c *APIClient) {
+		c.warmUp = true
+	}
+}
+
+// WithHeaderProfile sets the browser-identifying headers sent with API requests
+func WithHeaderProfile(profile HeaderProfile) Option {
+	return func(c *APIClient) {
+		c.headers = profile
+	}
+}
+
+// WithAppVersionDiscovery looks up the current app version from the reservation
+// site's front-end assets instead of trusting the configured one
+func WithAppVersionDiscovery() Option {
+	return func(c *APIClient) {
+		c.discoverVersion = true
+	}
+}
+
+// WithCache serves repeated queries from memory for up to ttl
+func WithCache(ttl time.Duration) Option {
+	return func(c *APIClient) {
+		if ttl > 0 {
+			c.cache = newResponseCache(ttl)
+		}
+	}
+}
+
+// WithMaxWindowDays caps how many days CheckLocation asks for in one request
+func WithMaxWindowDays(days int) Option {
+	return func(c *APIClient) {
+		c.maxWindowDays = days
+	}
+}
+
+// CacheStats reports response cache activity; it is zero when caching is disabled
+func (c *APIClient) CacheStats() CacheStats {
+	if c.cache == nil {
+		return CacheStats{}
+	}
+	return c.cache.stats()
 }
 
-func NewAPIClient() *APIClient {
-	return &APIClient{
+func NewAPIClient(opts ...Option) *APIClient {
+	// cookiejar.New only fails when given a broken public suffix list
+	jar, _ := cookiejar.New(nil)
+	c := &APIClient{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
+			Jar:     jar,
 		},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if len(c.proxies) > 0 {
+		c.pool = newProxyPool(c.proxies, c.proxyStrategy, c.client)
+	}
+	return c
 }
 
-func (c *APIClient) CheckAvailability(locationID int, startDate, endDate string, resourceIDs []int) ([]ResourceAvailability, error) {
-	url := fmt.Sprintf("https://reservation.pc.gc.ca/api/availability/dailyactivity?resourceLocationId=%d&startDate=%s&endDate=%s&bookingCategoryId=10",
-		locationID, startDate, endDate)
-
-	// Convert resource IDs to JSON
-	resourceIDsJSON, err := json.Marshal(resourceIDs)
-	if err != nil {
-		return nil, fmt.Errorf("error marshaling resource IDs: %w", err)
+// httpClient returns the client for requests that aren't tracked per egress,
+// such as the warm-up page load
+func (c *APIClient) httpClient() *http.Client {
+	if c.pool == nil {
+		return c.client
 	}
+	return c.pool.preferred().client
+}
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(resourceIDsJSON)))
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+// ensureWarmedUp performs the warm-up page load once per session. Failures are
+// logged rather than returned; the API request is attempted regardless.
+func (c *APIClient) ensureWarmedUp() {
+	if !c.warmUp {
+		return
 	}
 
-	// Add required headers
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/136.0.0.0 Safari/537.36")
-	req.Header.Add("App-Language", "en-CA")
-	req.Header.Add("app-version", "5.98.197")
+	c.warmMu.Lock()
+	defer c.warmMu.Unlock()
+	if c.warmedUp {
+		return
+	}
 
-	resp, err := c.client.Do(req)
+	req, err := http.NewRequest("GET", reservationBaseURL+"/", nil)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
+		log.Printf("Error creating warm-up request: %v", err)
+		return
 	}
-	defer resp.Body.Close()
+	req.Header.Add("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Add("Accept-Language", "en-CA,en;q=0.9")
+	req.Header.Add("User-Agent", c.headers.withDefaults().UserAgent)
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %w", err)
+		log.Printf("Error loading reservation site for warm-up: %v", err)
+		return
 	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+		log.Printf("Warm-up page load returned status %d", resp.StatusCode)
+		return
+	}
+	c.warmedUp = true
+}
+
+// resetWarmUp forces a fresh warm-up before the next request, used after the
+// site challenged us and the current session is likely no longer trusted
+func (c *APIClient) resetWarmUp() {
+	c.warmMu.Lock()
+	defer c.warmMu.Unlock()
+	c.warmedUp = false
+}
+
+func (c *APIClient) CheckAvailability(locationID int, startDate, endDate string, resourceIDs []int) ([]ResourceAvailability, error) {
+	url := fmt.Sprintf(reservationBaseURL+"/api/availability/dailyactivity?resourceLocationId=%d&startDate=%s&endDate=%s&bookingCategoryId=10",
+		locationID, startDate, endDate)
+
+	body, duration, err := c.post(url, resourceIDs)
+	if err != nil {
+		return nil, err
 	}
 
 	var availabilities []ResourceAvailability
 	if err := json.Unmarshal(body, &availabilities); err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %w", err)
+		return nil, decodeError(body, duration, err)
 	}
 
 	return availabilities, nil
 }
 
-// HasAvailability checks if specific resources have available quota
-func (c *APIClient) HasAvailability(urlName string, resourceLocationId int, startDate, endDate string, resourceIds []int64, bookingCategory int) (bool, []string, error) {
-	url := fmt.Sprintf("https://reservation.pc.gc.ca/api/availability/dailyactivity?resourceLocationId=%d&startDate=%s&endDate=%s&bookingCategoryId=%d",
-		resourceLocationId, startDate, endDate, bookingCategory)
-
+// post sends resourceIDs as a JSON body to the given availability URL and returns
+// the raw response body. Any failure is reported as an *UpstreamError.
+// With a proxy pool, requests that fail because of the egress are retried once
+// through each of the other egresses.
+func (c *APIClient) post(url string, resourceIDs any) ([]byte, time.Duration, error) {
 	// Convert resource IDs to JSON
-	resourceIDsJSON, err := json.Marshal(resourceIds)
+	resourceIDsJSON, err := json.Marshal(resourceIDs)
 	if err != nil {
-		return false, nil, fmt.Errorf("error marshaling resource IDs: %w", err)
+		return nil, 0, fmt.Errorf("error marshaling resource IDs: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(resourceIDsJSON)))
+	if c.pool == nil {
+		return c.send(c.client, url, resourceIDsJSON)
+	}
+
+	var (
+		body     []byte
+		duration time.Duration
+	)
+	tried := make(map[*egress]bool)
+	for attempt := 0; attempt < c.pool.size(); attempt++ {
+		e := c.pool.pick(tried)
+		tried[e] = true
+		body, duration, err = c.send(e.client, url, resourceIDsJSON)
+		c.pool.record(e, err)
+		if err == nil || !isEgressFailure(err) {
+			break
+		}
+	}
+	return body, duration, err
+}
+
+// send performs a single availability request through client
+func (c *APIClient) send(client *http.Client, url string, payload []byte) ([]byte, time.Duration, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
 	if err != nil {
-		return false, nil, fmt.Errorf("error creating request: %w", err)
+		return nil, 0, fmt.Errorf("error creating request: %w", err)
 	}
 
 	// Add required headers
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/136.0.0.0 Safari/537.36")
-	req.Header.Add("App-Language", "en-CA")
-	req.Header.Add("app-version", "5.98.197")
 
-	resp, err := c.client.Do(req)
+	c.ensureWarmedUp()
+	c.headerProfile().apply(req)
+
+	start := time.Now()
+	resp, err := client.Do(req)
 	if err != nil {
-		return false, nil, fmt.Errorf("error making request: %w", err)
+		duration := time.Since(start)
+		return nil, duration, &UpstreamError{Kind: ErrUnreachable, Duration: duration, Err: err}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
+	duration := time.Since(start)
 	if err != nil {
-		return false, nil, fmt.Errorf("error reading response: %w", err)
+		return nil, duration, &UpstreamError{Kind: ErrUnreachable, StatusCode: resp.StatusCode, Duration: duration, Err: err}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return false, nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+	if summary, kind := detectChallenge(resp, body); kind != nil {
+		c.resetWarmUp()
+		return nil, duration, &UpstreamError{Kind: kind, StatusCode: resp.StatusCode, Body: summary, Duration: duration}
 	}
 
-	// First try to parse with the standard time format
-	var availabilities []ResourceAvailability
-	if err := json.Unmarshal(body, &availabilities); err != nil {
-		// If that fails, try with a custom time format
-		var rawData []map[string]json.RawMessage
-		if err := json.Unmarshal(body, &rawData); err != nil {
-			return false, nil, fmt.Errorf("error unmarshaling response: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		kind := kindForStatus(resp.StatusCode)
+		if kind == ErrInvalidRequest || kind == ErrBlocked {
+			c.markAppVersionStale()
 		}
+		return nil, duration, &UpstreamError{Kind: kind, StatusCode: resp.StatusCode, Body: truncateBody(body), Duration: duration}
+	}
+
+	return body, duration, nil
+}
+
+// AvailabilityQuery identifies one daily-activity request to the reservation API
+type AvailabilityQuery struct {
+	LocationID      int
+	BookingCategory int
+	StartDate       string
+	EndDate         string
+	ResourceIDs     []int64
+	// ForceRefresh bypasses the response cache
+	ForceRefresh bool
+}
+
+// AvailabilitySnapshot is the decoded answer to an AvailabilityQuery
+type AvailabilitySnapshot struct {
+	Availabilities []ResourceAvailability
+	FetchedAt      time.Time
+	// Cached reports whether the answer was served from the response cache
+	Cached bool
+}
+
+// Age returns how long ago the snapshot was fetched from upstream
+func (s *AvailabilitySnapshot) Age() time.Duration {
+	return time.Since(s.FetchedAt)
+}
 
-		// Process each item manually
-		availabilities = make([]ResourceAvailability, len(rawData))
-		for i, raw := range rawData {
-			var resourceID int
-			if err := json.Unmarshal(raw["resourceId"], &resourceID); err != nil {
-				return false, nil, fmt.Errorf("error unmarshaling resourceId: %w", err)
-			}
-
-			var rangeData map[string]string
-			if err := json.Unmarshal(raw["range"], &rangeData); err != nil {
-				return false, nil, fmt.Errorf("error unmarshaling range: %w", err)
-			}
-
-			start, err := time.Parse("2006-01-02T15:04:05", rangeData["start"])
-			if err != nil {
-				return false, nil, fmt.Errorf("error parsing start time: %w", err)
-			}
-
-			end, err := time.Parse("2006-01-02T15:04:05", rangeData["end"])
-			if err != nil {
-				return false, nil, fmt.Errorf("error parsing end time: %w", err)
-			}
-
-			var result AvailabilityResult
-			if err := json.Unmarshal(raw["availabilityResult"], &result); err != nil {
-				return false, nil, fmt.Errorf("error unmarshaling availabilityResult: %w", err)
-			}
-
-			availabilities[i] = ResourceAvailability{
-				ResourceID: resourceID,
-				Range: AvailabilityRange{
-					Start: start,
-					End:   end,
-				},
-				AvailabilityResult: result,
-			}
+// AvailableDates returns the sorted dates on which every resource in resourceIDs
+// has reservable quota
+func (s *AvailabilitySnapshot) AvailableDates(resourceIDs []int64) []string {
+	return NewAvailabilityMatrix(s.Availabilities).AvailableDates(resourceIDs)
+}
+
+// FetchAvailability returns the daily activity for q, from the response cache
+// when a fresh enough answer is available
+func (c *APIClient) FetchAvailability(q AvailabilityQuery) (*AvailabilitySnapshot, error) {
+	if c.cache != nil && !q.ForceRefresh {
+		if snapshot, ok := c.cache.get(q); ok {
+			return snapshot, nil
 		}
 	}
 
-	availableDates := make(map[string]struct{})
-	
-	// Group availabilities by date to ensure both resources are available
-	dateResources := make(map[string]map[int]float64)
-	
-	for _, avail := range availabilities {
-		date := avail.Range.Start.Format("2006-01-02")
-		if _, exists := dateResources[date]; !exists {
-			dateResources[date] = make(map[int]float64)
-		}
-		dateResources[date][avail.ResourceID] = avail.AvailabilityResult.RemainingReservableQuota
-	}
-
-	// Check each date for all required resource IDs
-	for date, resources := range dateResources {
-		allResourcesAvailable := true
-		for _, resourceID := range resourceIds {
-			quota, exists := resources[int(resourceID)]
-			if !exists || quota <= 0 {
-				allResourcesAvailable = false
-				break
-			}
-		}
-		if allResourcesAvailable {
-			availableDates[date] = struct{}{}
+	if c.breaker != nil {
+		if err := c.breaker.Allow(); err != nil {
+			return nil, err
 		}
 	}
 
-	// Convert map to sorted slice
-	dates := make([]string, 0, len(availableDates))
-	for date := range availableDates {
-		dates = append(dates, date)
+	url := fmt.Sprintf(reservationBaseURL+"/api/availability/dailyactivity?resourceLocationId=%d&startDate=%s&endDate=%s&bookingCategoryId=%d",
+		q.LocationID, q.StartDate, q.EndDate, q.BookingCategory)
+
+	body, duration, err := c.post(url, q.ResourceIDs)
+	var availabilities []ResourceAvailability
+	if err == nil {
+		availabilities, err = decodeAvailabilities(body, duration)
+	}
+	if c.breaker != nil {
+		c.breaker.Record(err)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &AvailabilitySnapshot{
+		Availabilities: availabilities,
+		FetchedAt:      time.Now(),
+	}
+	if c.cache != nil {
+		c.cache.put(q, snapshot)
+	}
+	return snapshot, nil
+}
 
+// HasAvailability checks if specific resources have available quota
+func (c *APIClient) HasAvailability(urlName string, resourceLocationId int, startDate, endDate string, resourceIds []int64, bookingCategory int) (bool, []string, error) {
+	snapshot, err := c.FetchAvailability(AvailabilityQuery{
+		LocationID:      resourceLocationId,
+		BookingCategory: bookingCategory,
+		StartDate:       startDate,
+		EndDate:         endDate,
+		ResourceIDs:     resourceIds,
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	dates := snapshot.AvailableDates(resourceIds)
 	return len(dates) > 0, dates, nil
-} 
\ No newline at end of file
+}
+
+func decodeAvailabilities(body []byte, duration time.Duration) ([]ResourceAvailability, error) {
+	var availabilities []ResourceAvailability
+	if err := json.Unmarshal(body, &availabilities); err != nil {
+		return nil, decodeError(body, duration, err)
+	}
+	return availabilities, nil
+}
@@ -1,11 +1,13 @@
 package shuttle
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 type mockTransport struct {
@@ -200,6 +202,24 @@ func TestHasAvailability(t *testing.T) {
 			wantDates:     []string{"2025-08-05"},
 			wantErr:       false,
 		},
+		{
+			name:               "Zone-less timestamps",
+			urlName:            "Lake O'Hara",
+			resourceLocationID: -2147483536,
+			startDate:          "2025-08-05",
+			endDate:            "2025-08-06",
+			resourceIDs:        []int64{-2147479230},
+			bookingCategory:    10,
+			expectedURL:        "https://reservation.pc.gc.ca/api/availability/dailyactivity?resourceLocationId=-2147483536&startDate=2025-08-05&endDate=2025-08-06&bookingCategoryId=10",
+			expectedBody:       `[-2147479230]`,
+			mockResponse: `[
+				{"resourceId":-2147479230,"range":{"start":"2025-08-05T00:00:00","end":"2025-08-05T00:00:00"},"availabilityResult":{"resultCode":0,"resourceId":-2147479230,"startDate":"2025-08-05T00:00:00","endDate":"2025-08-06T00:00:00","remainingReservableQuota":0,"remainingTotalQuota":0,"closedQuota":0}},
+				{"resourceId":-2147479230,"range":{"start":"2025-08-06T00:00:00","end":"2025-08-06T00:00:00"},"availabilityResult":{"resultCode":0,"resourceId":-2147479230,"startDate":"2025-08-06T00:00:00","endDate":"2025-08-07T00:00:00","remainingReservableQuota":3,"remainingTotalQuota":3,"closedQuota":0}}
+			]`,
+			wantAvailable: true,
+			wantDates:     []string{"2025-08-06"},
+			wantErr:       false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -248,4 +268,360 @@ func TestHasAvailability(t *testing.T) {
 			}
 		})
 	}
-} 
\ No newline at end of file
+} 
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHasAvailabilityErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		transport  error
+		wantKind   error
+	}{
+		{name: "rate limited", statusCode: http.StatusTooManyRequests, body: "slow down", wantKind: ErrRateLimited},
+		{name: "forbidden", statusCode: http.StatusForbidden, body: "denied", wantKind: ErrBlocked},
+		{name: "server error", statusCode: http.StatusBadGateway, body: "bad gateway", wantKind: ErrServer},
+		{name: "bad request", statusCode: http.StatusBadRequest, body: "{}", wantKind: ErrInvalidRequest},
+		{name: "unexpected shape", statusCode: http.StatusOK, body: `{"not":"a list"}`, wantKind: ErrDecode},
+		{name: "transport failure", transport: io.ErrUnexpectedEOF, wantKind: ErrUnreachable},
+		{name: "waf challenge", statusCode: http.StatusOK, body: `<html><head><title>Just a moment...</title></head><body>captcha</body></html>`, wantKind: ErrBlocked},
+		{name: "unrecognised html", statusCode: http.StatusOK, body: `<!DOCTYPE html><html><body>Hello</body></html>`, wantKind: ErrBlocked},
+		{name: "queue-it waiting room", statusCode: http.StatusOK, body: `<html><script src="//static.queue-it.net/script/queueclient.min.js"></script></html>`, wantKind: ErrQueued},
+		{name: "html error page", statusCode: http.StatusBadGateway, body: `<html><head><title>502</title></head><body>Access denied to upstream. Incident ID: 42</body></html>`, wantKind: ErrServer},
+		{name: "html outage page mentioning queue-it", statusCode: http.StatusServiceUnavailable, body: `<html><script src="//static.queue-it.net/script/queueclient.min.js"></script></html>`, wantKind: ErrServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &APIClient{
+				client: &http.Client{
+					Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+						if tt.transport != nil {
+							return nil, tt.transport
+						}
+						return &http.Response{
+							StatusCode: tt.statusCode,
+							Body:       io.NopCloser(strings.NewReader(tt.body)),
+						}, nil
+					}),
+				},
+			}
+
+			_, _, err := client.HasAvailability("Test", 1, "2025-08-05", "2025-08-05", []int64{1}, 9)
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("HasAvailability() error = %v, want kind %v", err, tt.wantKind)
+			}
+
+			var upstreamErr *UpstreamError
+			if !errors.As(err, &upstreamErr) {
+				t.Fatalf("HasAvailability() error = %T, want *UpstreamError", err)
+			}
+			if upstreamErr.StatusCode != tt.statusCode {
+				t.Errorf("StatusCode = %d, want %d", upstreamErr.StatusCode, tt.statusCode)
+			}
+		})
+	}
+}
+
+func TestChallengeReportsPageTitle(t *testing.T) {
+	client := &APIClient{
+		client: &http.Client{
+			Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusForbidden,
+					Header:     http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+					Body:       io.NopCloser(strings.NewReader("<html><head><title>Access Denied</title></head><body>" + strings.Repeat("x", 4096) + "</body></html>")),
+				}, nil
+			}),
+		},
+	}
+
+	_, _, err := client.HasAvailability("Test", 1, "2025-08-05", "2025-08-05", []int64{1}, 9)
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Fatalf("HasAvailability() error = %v, want *UpstreamError", err)
+	}
+	if upstreamErr.Body != "HTML page: Access Denied" {
+		t.Errorf("Body = %q, want page title", upstreamErr.Body)
+	}
+}
+
+func TestUpstreamErrorTruncatesBody(t *testing.T) {
+	long := strings.Repeat("x", maxErrorBodyLen*2)
+	client := &APIClient{
+		client: &http.Client{
+			Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Body:       io.NopCloser(strings.NewReader(long)),
+				}, nil
+			}),
+		},
+	}
+
+	_, _, err := client.HasAvailability("Test", 1, "2025-08-05", "2025-08-05", []int64{1}, 9)
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Fatalf("HasAvailability() error = %v, want *UpstreamError", err)
+	}
+	if len(upstreamErr.Body) > maxErrorBodyLen+3 {
+		t.Errorf("Body length = %d, want at most %d", len(upstreamErr.Body), maxErrorBodyLen+3)
+	}
+}
+
+func TestWarmUpSessionCookie(t *testing.T) {
+	client := NewAPIClient(WithWarmUp())
+	var warmUps int
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" {
+			warmUps++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Set-Cookie": []string{"session=abc; Path=/"}},
+				Body:       io.NopCloser(strings.NewReader("<html></html>")),
+				Request:    req,
+			}, nil
+		}
+		if cookie, err := req.Cookie("session"); err != nil || cookie.Value != "abc" {
+			t.Errorf("API request missing session cookie: %v", err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("[]")),
+			Request:    req,
+		}, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := client.HasAvailability("Test", 1, "2025-08-05", "2025-08-05", []int64{1}, 9); err != nil {
+			t.Fatalf("HasAvailability() error = %v", err)
+		}
+	}
+	if warmUps != 1 {
+		t.Errorf("warm-up ran %d times, want 1", warmUps)
+	}
+}
+
+func TestHeaderProfileOverride(t *testing.T) {
+	client := NewAPIClient(WithHeaderProfile(HeaderProfile{
+		AppVersion: "6.0.1",
+		Extra:      map[string]string{"Sec-CH-UA-Platform": `"macOS"`},
+	}))
+	client.client.Transport = &mockTransport{
+		expectedURL:    "https://reservation.pc.gc.ca/api/availability/dailyactivity?resourceLocationId=1&startDate=2025-08-05&endDate=2025-08-05&bookingCategoryId=9",
+		expectedMethod: "POST",
+		expectedHeaders: map[string]string{
+			"App-Language":       "en-CA",
+			"app-version":        "6.0.1",
+			"Sec-CH-UA-Platform": `"macOS"`,
+		},
+		expectedBody: `[1]`,
+		response:     `[]`,
+		t:            t,
+	}
+
+	if _, _, err := client.HasAvailability("Test", 1, "2025-08-05", "2025-08-05", []int64{1}, 9); err != nil {
+		t.Fatalf("HasAvailability() error = %v", err)
+	}
+}
+
+func TestDiscoverAppVersion(t *testing.T) {
+	client := NewAPIClient(WithAppVersionDiscovery())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body string
+		switch req.URL.Path {
+		case "/":
+			body = `<html><head><script src="runtime.1a2b.js"></script><script src="main.3c4d.js" type="module"></script></head></html>`
+		case "/runtime.1a2b.js":
+			body = `(()=>{"use strict";})()`
+		case "/main.3c4d.js":
+			body = `const e={production:!0,version:"5.99.4",apiUrl:"/api"};`
+		case "/api/availability/dailyactivity":
+			if got := req.Header.Get("app-version"); got != "5.99.4" {
+				t.Errorf("app-version = %q, want discovered 5.99.4", got)
+			}
+			body = `[]`
+		default:
+			t.Errorf("unexpected request to %s", req.URL)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+
+	if _, _, err := client.HasAvailability("Test", 1, "2025-08-05", "2025-08-05", []int64{1}, 9); err != nil {
+		t.Fatalf("HasAvailability() error = %v", err)
+	}
+}
+
+func TestDiscoverAppVersionFailureIsNotRetriedPerRequest(t *testing.T) {
+	discoveries := 0
+	client := NewAPIClient(WithAppVersionDiscovery())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/" {
+			discoveries++
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       io.NopCloser(strings.NewReader("down")),
+				Request:    req,
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`[]`)),
+			Request:    req,
+		}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := client.HasAvailability("Test", 1, "2025-08-05", "2025-08-05", []int64{1}, 9); err != nil {
+			t.Fatalf("HasAvailability() error = %v", err)
+		}
+	}
+	if discoveries != 1 {
+		t.Errorf("discovery ran %d times, want 1", discoveries)
+	}
+}
+
+func TestDiscoverAppVersionDoesNotBlockOtherRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	client := NewAPIClient(WithAppVersionDiscovery())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/" {
+			close(started)
+			<-release
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       io.NopCloser(strings.NewReader("down")),
+				Request:    req,
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`[]`)),
+			Request:    req,
+		}, nil
+	})
+
+	first := make(chan error)
+	go func() {
+		_, _, err := client.HasAvailability("Test", 1, "2025-08-05", "2025-08-05", []int64{1}, 9)
+		first <- err
+	}()
+	<-started
+
+	// Discovery is still waiting for the landing page
+	done := make(chan error)
+	go func() {
+		_, _, err := client.HasAvailability("Test", 1, "2025-08-06", "2025-08-06", []int64{1}, 9)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("HasAvailability() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request waited for app version discovery")
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatalf("HasAvailability() error = %v", err)
+	}
+}
+
+func TestFetchAvailabilityCache(t *testing.T) {
+	var requests int
+	client := NewAPIClient(WithCache(time.Minute))
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`[{"resourceId":1,"range":{"start":"2025-08-05T00:00:00Z","end":"2025-08-05T00:00:00Z"},"availabilityResult":{"remainingReservableQuota":3}}]`)),
+			Request:    req,
+		}, nil
+	})
+
+	query := AvailabilityQuery{LocationID: 1, BookingCategory: 9, StartDate: "2025-08-05", EndDate: "2025-08-05", ResourceIDs: []int64{2, 1}}
+	first, err := client.FetchAvailability(query)
+	if err != nil {
+		t.Fatalf("FetchAvailability() error = %v", err)
+	}
+	if first.Cached {
+		t.Errorf("first answer marked as cached")
+	}
+
+	// Same resource set in a different order hits the same entry
+	query.ResourceIDs = []int64{1, 2}
+	second, err := client.FetchAvailability(query)
+	if err != nil {
+		t.Fatalf("FetchAvailability() error = %v", err)
+	}
+	if !second.Cached || requests != 1 {
+		t.Errorf("second answer cached = %v after %d requests, want cached after 1", second.Cached, requests)
+	}
+
+	query.ForceRefresh = true
+	third, err := client.FetchAvailability(query)
+	if err != nil {
+		t.Fatalf("FetchAvailability() error = %v", err)
+	}
+	if third.Cached || requests != 2 {
+		t.Errorf("forced answer cached = %v after %d requests, want fresh after 2", third.Cached, requests)
+	}
+
+	stats := client.CacheStats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("CacheStats() = %+v, want 1 hit, 1 miss, 1 entry", stats)
+	}
+}
+
+func TestCheckLocationMergesWindows(t *testing.T) {
+	var windows []string
+	client := &APIClient{
+		client: &http.Client{
+			Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				start := req.URL.Query().Get("startDate")
+				windows = append(windows, start+".."+req.URL.Query().Get("endDate"))
+				// Every day in the window has one seat left on both resources
+				body := `[
+					{"resourceId":1,"range":{"start":"` + start + `T00:00:00Z","end":"` + start + `T00:00:00Z"},"availabilityResult":{"remainingReservableQuota":1}},
+					{"resourceId":2,"range":{"start":"` + start + `T00:00:00Z","end":"` + start + `T00:00:00Z"},"availabilityResult":{"remainingReservableQuota":1}}
+				]`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader(body)),
+					Request:    req,
+				}, nil
+			}),
+		},
+	}
+
+	loc := Location{
+		Name:            "Test",
+		LocationID:      1,
+		ResourceIDs:     []int64{1, 2},
+		Dates:           []string{"2025-06-01", "2025-08-30"},
+		BookingCategory: 9,
+	}
+	availability, err := client.CheckLocation(loc, false)
+	if err != nil {
+		t.Fatalf("CheckLocation() error = %v", err)
+	}
+
+	wantWindows := []string{"2025-06-01..2025-06-01", "2025-08-30..2025-08-30"}
+	if !reflect.DeepEqual(windows, wantWindows) {
+		t.Errorf("requested windows = %v, want %v", windows, wantWindows)
+	}
+	if got := availability.AvailableDates(); !reflect.DeepEqual(got, loc.Dates) {
+		t.Errorf("AvailableDates() = %v, want %v", got, loc.Dates)
+	}
+}
@@ -0,0 +1,189 @@
+package shuttle
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/store"
+)
+
+// BreakerState is the state of a CircuitBreaker
+type BreakerState string
+
+const (
+	// BreakerClosed lets every request through
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects requests until the cool-down has passed
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single probe request through to test recovery
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// CircuitBreaker stops requests to the reservation API while it is down, so a
+// maintenance window costs one alert instead of an error per location per cycle
+type CircuitBreaker struct {
+	// FailureThreshold consecutive outage errors open the circuit
+	FailureThreshold int
+	// OpenDuration is how long the circuit stays open before a probe is allowed
+	OpenDuration time.Duration
+	// OnStateChange, if set, is called after every transition. It runs
+	// synchronously, outside the breaker's lock.
+	OnStateChange func(from, to BreakerState, cause error)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	lastErr  error
+	// path is where the state is saved, if anywhere
+	path string
+}
+
+// breakerSnapshot is the part of a CircuitBreaker saved between runs
+type breakerSnapshot struct {
+	State    BreakerState `json:"state"`
+	Failures int          `json:"failures"`
+	OpenedAt time.Time    `json:"openedAt"`
+	LastErr  string       `json:"lastError,omitempty"`
+}
+
+// NewCircuitBreaker creates a closed breaker
+func NewCircuitBreaker(failureThreshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		OpenDuration:     openDuration,
+		state:            BreakerClosed,
+	}
+}
+
+// Restore loads the state saved at path, if any, and saves every change there
+// from now on. The checker exits between scheduled runs, so without this an
+// outage would trip the breaker and alert again on every run, and the
+// recovery would never be noticed.
+func (b *CircuitBreaker) Restore(path string) error {
+	var saved breakerSnapshot
+	if err := store.Load(path, &saved); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.path = path
+	if saved.State == "" {
+		return nil
+	}
+	// probing is not restored: a probe under way when the last run exited
+	// never finished, so another one may go
+	b.state = saved.State
+	b.failures = saved.Failures
+	b.openedAt = saved.OpenedAt
+	if saved.LastErr != "" {
+		b.lastErr = errors.New(saved.LastErr)
+	}
+	return nil
+}
+
+// save writes the state to path; b.mu must be held
+func (b *CircuitBreaker) save() {
+	if b.path == "" {
+		return
+	}
+	saved := breakerSnapshot{State: b.state, Failures: b.failures, OpenedAt: b.openedAt}
+	if b.lastErr != nil {
+		saved.LastErr = b.lastErr.Error()
+	}
+	if err := store.Save(b.path, saved); err != nil {
+		log.Printf("Error saving circuit breaker state: %v", err)
+	}
+}
+
+// WithCircuitBreaker guards API requests with b
+func WithCircuitBreaker(b *CircuitBreaker) Option {
+	return func(c *APIClient) {
+		c.breaker = b
+	}
+}
+
+// BreakerState reports the state of the client's circuit breaker, or closed if it has none
+func (c *APIClient) BreakerState() BreakerState {
+	if c.breaker == nil {
+		return BreakerClosed
+	}
+	return c.breaker.State()
+}
+
+// State returns the current state
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow reports whether a request may be sent now. It returns an error
+// wrapping ErrCircuitOpen while the circuit is open or a probe is in flight.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case b.state == BreakerOpen && time.Since(b.openedAt) >= b.OpenDuration:
+		b.state = BreakerHalfOpen
+		b.probing = true
+	case b.state == BreakerOpen || (b.state == BreakerHalfOpen && b.probing):
+		err := fmt.Errorf("%w since %s: %v", ErrCircuitOpen, b.openedAt.Format(time.RFC3339), b.lastErr)
+		b.mu.Unlock()
+		return err
+	case b.state == BreakerHalfOpen:
+		b.probing = true
+	}
+	to := b.state
+	if from != to {
+		b.save()
+	}
+	b.mu.Unlock()
+
+	b.notify(from, to, nil)
+	return nil
+}
+
+// Record feeds the outcome of an allowed request back into the breaker
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	from := b.state
+	failures := b.failures
+	b.probing = false
+	if err == nil || !isOutage(err) {
+		b.failures = 0
+		b.state = BreakerClosed
+	} else {
+		b.failures++
+		b.lastErr = err
+		if b.state == BreakerHalfOpen || b.failures >= b.FailureThreshold {
+			b.state = BreakerOpen
+			b.openedAt = time.Now()
+		}
+	}
+	to := b.state
+	if from != to || failures != b.failures {
+		b.save()
+	}
+	b.mu.Unlock()
+
+	b.notify(from, to, err)
+}
+
+func (b *CircuitBreaker) notify(from, to BreakerState, cause error) {
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(from, to, cause)
+	}
+}
+
+// isOutage reports errors that mean the reservation API itself is unavailable,
+// as opposed to it refusing us in particular
+func isOutage(err error) bool {
+	return errors.Is(err, ErrServer) ||
+		errors.Is(err, ErrUnreachable) ||
+		errors.Is(err, ErrQueued)
+}
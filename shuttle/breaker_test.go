@@ -0,0 +1,113 @@
+package shuttle
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	var transitions []string
+	b := NewCircuitBreaker(2, time.Hour)
+	b.OnStateChange = func(from, to BreakerState, cause error) {
+		transitions = append(transitions, string(from)+"->"+string(to))
+	}
+	outage := &UpstreamError{Kind: ErrServer, StatusCode: 503}
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow() error = %v while closed", err)
+		}
+		b.Record(outage)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("State() = %s after threshold, want open", b.State())
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() error = %v while open, want ErrCircuitOpen", err)
+	}
+
+	// Pretend the cool-down has passed: one probe is let through
+	b.openedAt = time.Now().Add(-2 * time.Hour)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() error = %v after cool-down", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Allow() let a second probe through, error = %v", err)
+	}
+	b.Record(nil)
+
+	if b.State() != BreakerClosed {
+		t.Errorf("State() = %s after successful probe, want closed", b.State())
+	}
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transitions = %v, want %v", transitions, want)
+			break
+		}
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Hour)
+	b.Record(&UpstreamError{Kind: ErrRateLimited, StatusCode: 429})
+	b.Record(&UpstreamError{Kind: ErrInvalidRequest, StatusCode: 400})
+	if b.State() != BreakerClosed {
+		t.Errorf("State() = %s after client errors, want closed", b.State())
+	}
+}
+
+func TestCircuitBreakerRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breaker.json")
+	first := NewCircuitBreaker(2, time.Hour)
+	if err := first.Restore(path); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	outage := &UpstreamError{Kind: ErrServer, StatusCode: 503}
+	first.Record(outage)
+	first.Record(outage)
+
+	// The next run starts where the last one stopped, without a new alert
+	var transitions []string
+	second := NewCircuitBreaker(2, time.Hour)
+	second.OnStateChange = func(from, to BreakerState, cause error) {
+		transitions = append(transitions, string(from)+"->"+string(to))
+	}
+	if err := second.Restore(path); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if second.State() != BreakerOpen {
+		t.Fatalf("State() = %s after restore, want open", second.State())
+	}
+	if err := second.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() error = %v while open, want ErrCircuitOpen", err)
+	}
+
+	// A later run sees the recovery
+	third := NewCircuitBreaker(2, time.Nanosecond)
+	third.OnStateChange = second.OnStateChange
+	if err := third.Restore(path); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if err := third.Allow(); err != nil {
+		t.Fatalf("Allow() error = %v after cool-down", err)
+	}
+	third.Record(nil)
+	want := []string{"open->half-open", "half-open->closed"}
+	if len(transitions) != len(want) || transitions[0] != want[0] || transitions[1] != want[1] {
+		t.Errorf("transitions = %v, want %v", transitions, want)
+	}
+
+	fourth := NewCircuitBreaker(2, time.Hour)
+	if err := fourth.Restore(path); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if fourth.State() != BreakerClosed {
+		t.Errorf("State() = %s after recovery, want closed", fourth.State())
+	}
+}
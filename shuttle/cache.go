@@ -0,0 +1,98 @@
+package shuttle
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CacheStats is a point-in-time view of response cache activity
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+type cacheEntry struct {
+	snapshot *AvailabilitySnapshot
+	expires  time.Time
+}
+
+// responseCache keeps decoded availability answers for a short time so
+// back-to-back checks of the same watch don't hit the reservation API twice
+type responseCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]cacheEntry
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// cacheKey identifies a query independently of resource ID order
+func cacheKey(q AvailabilityQuery) string {
+	ids := append([]int64(nil), q.ResourceIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return fmt.Sprintf("%d|%d|%s|%s|%v", q.LocationID, q.BookingCategory, q.StartDate, q.EndDate, ids)
+}
+
+func (rc *responseCache) get(q AvailabilityQuery) (*AvailabilitySnapshot, bool) {
+	key := cacheKey(q)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entry, ok := rc.entries[key]
+	if ok && time.Now().After(entry.expires) {
+		delete(rc.entries, key)
+		rc.evictions++
+		ok = false
+	}
+	if !ok {
+		rc.misses++
+		return nil, false
+	}
+	rc.hits++
+
+	cached := *entry.snapshot
+	cached.Cached = true
+	return &cached, true
+}
+
+func (rc *responseCache) put(q AvailabilityQuery, snapshot *AvailabilitySnapshot) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range rc.entries {
+		if now.After(entry.expires) {
+			delete(rc.entries, key)
+			rc.evictions++
+		}
+	}
+	rc.entries[cacheKey(q)] = cacheEntry{
+		snapshot: snapshot,
+		expires:  now.Add(rc.ttl),
+	}
+}
+
+func (rc *responseCache) stats() CacheStats {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return CacheStats{
+		Hits:      rc.hits,
+		Misses:    rc.misses,
+		Evictions: rc.evictions,
+		Entries:   len(rc.entries),
+	}
+}
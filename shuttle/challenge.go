@@ -0,0 +1,88 @@
+package shuttle
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// queueMarkers identify a Queue-it waiting room page
+var queueMarkers = []string{
+	"queue-it",
+	"queueit",
+}
+
+// challengeMarkers identify bot-protection interstitials (WAF blocks, captchas,
+// JavaScript challenges) that are served instead of the API response
+var challengeMarkers = []string{
+	"captcha",
+	"_incapsula_resource",
+	"incident id",
+	"cf-chl",
+	"cf_chl",
+	"just a moment",
+	"access denied",
+	"request unsuccessful",
+	"challenge-platform",
+}
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// detectChallenge checks whether resp is a bot-protection or waiting room page
+// rather than an API answer. It returns a short description of the page and
+// the error kind to report, or a nil kind if the response looks genuine.
+func detectChallenge(resp *http.Response, body []byte) (string, error) {
+	if resp.Request != nil && strings.Contains(strings.ToLower(resp.Request.URL.Host), "queue-it") {
+		return "redirected to " + resp.Request.URL.Host, ErrQueued
+	}
+
+	// Bot protection and waiting rooms answer 200, 403 or 429. An HTML error
+	// page with any other status is an ordinary outage, whatever it mentions.
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusForbidden, http.StatusTooManyRequests:
+	default:
+		return "", nil
+	}
+	if !isHTML(resp, body) {
+		return "", nil
+	}
+
+	summary := pageTitle(body)
+	lower := strings.ToLower(string(body))
+	for _, marker := range queueMarkers {
+		if strings.Contains(lower, marker) {
+			return summary, ErrQueued
+		}
+	}
+	for _, marker := range challengeMarkers {
+		if strings.Contains(lower, marker) {
+			return summary, ErrBlocked
+		}
+	}
+
+	// An HTML page where JSON was expected is never a usable answer, even when
+	// we don't recognise the vendor
+	if resp.StatusCode == http.StatusOK {
+		return summary, ErrBlocked
+	}
+	return "", nil
+}
+
+func isHTML(resp *http.Response, body []byte) bool {
+	if strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+		return true
+	}
+	trimmed := bytes.TrimSpace(body)
+	return bytes.HasPrefix(trimmed, []byte("<"))
+}
+
+// pageTitle returns the <title> of an HTML page, or a placeholder when it has none
+func pageTitle(body []byte) string {
+	if m := titlePattern.FindSubmatch(body); m != nil {
+		if title := strings.TrimSpace(string(m[1])); title != "" {
+			return "HTML page: " + title
+		}
+	}
+	return "HTML page"
+}
@@ -1,7 +1,9 @@
 package shuttle
 
 type Location struct {
+	ID               string // stable key used in configuration, e.g. "lake-ohara"
 	Name             string
+	Place            string // destination served, e.g. "Moraine Lake"
 	LocationID       int
 	ResourceIDs      []int64
 	Dates           []string
@@ -10,7 +12,9 @@ type Location struct {
 
 var (
 	LakeMorainMorning = Location{
+		ID:               "moraine-morning",
 		Name:             "Lake Morain Morning",
+		Place:            "Moraine Lake",
 		LocationID:       -2147483642,
 		ResourceIDs:      []int64{-2147476652, -2147476634, -2147476641, -2147476655},
 		Dates:           []string{"2025-08-05", "2025-08-06", "2025-08-07"},
@@ -18,7 +22,9 @@ var (
 	}
 
 	LakeMorainMidday = Location{
+		ID:               "moraine-midday",
 		Name:             "Lake Morain Midday",
+		Place:            "Moraine Lake",
 		LocationID:       -2147483642,
 		ResourceIDs:      []int64{-2147476651, -2147476653},
 		Dates:           []string{"2025-08-05", "2025-08-06", "2025-08-07"},
@@ -26,7 +32,9 @@ var (
 	}
 
 	LakeOHara = Location{
+		ID:               "lake-ohara",
 		Name:             "Lake O'Hara",
+		Place:            "Lake O'Hara",
 		LocationID:       -2147483536,
 		ResourceIDs:      []int64{-2147479230, -2147479229},
 		Dates:           []string{"2025-08-05", "2025-08-06", "2025-08-07"},
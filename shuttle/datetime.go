@@ -0,0 +1,92 @@
+package shuttle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+	// Embedded so ParkTimeZone resolves in minimal containers without tzdata
+	_ "time/tzdata"
+)
+
+// ParkTimeZone is the time zone of the watched parks (Banff and Yoho), used for
+// timestamps the reservation API sends without an offset
+var ParkTimeZone = mustLoadLocation("America/Edmonton")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(fmt.Sprintf("loading time zone %s: %v", name, err))
+	}
+	return loc
+}
+
+// zonedLayouts carry their own offset
+var zonedLayouts = []string{
+	time.RFC3339Nano,
+}
+
+// localLayouts have no offset and are interpreted in ParkTimeZone
+var localLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	dateLayout,
+}
+
+// DateTime is a timestamp from the reservation API. It accepts RFC 3339 values,
+// zone-less local timestamps and plain dates; the latter two are taken to be
+// wall-clock time in ParkTimeZone.
+type DateTime struct {
+	time.Time
+}
+
+// ParseDateTime parses s in any of the formats accepted by DateTime
+func ParseDateTime(s string) (DateTime, error) {
+	for _, layout := range zonedLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return DateTime{t}, nil
+		}
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, s, ParkTimeZone); err == nil {
+			return DateTime{t}, nil
+		}
+	}
+	return DateTime{}, fmt.Errorf("unrecognised date-time %q", s)
+}
+
+func (d *DateTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = DateTime{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("date-time must be a string: %w", err)
+	}
+	if s == "" {
+		*d = DateTime{}
+		return nil
+	}
+	parsed, err := ParseDateTime(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d DateTime) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.Format(time.RFC3339))
+}
+
+// Date returns the calendar date (2006-01-02) on the wall clock the value was
+// given in. It deliberately doesn't convert between zones: the API marks days
+// with midnight timestamps, and converting "2025-08-05T00:00:00Z" to Mountain
+// time would move it to August 4.
+func (d DateTime) Date() string {
+	return d.Format(dateLayout)
+}
@@ -0,0 +1,47 @@
+package shuttle
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDateTimeUnmarshal(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantDate string
+		wantUTC  string
+	}{
+		{input: `"2025-08-05T00:00:00Z"`, wantDate: "2025-08-05", wantUTC: "2025-08-05T00:00:00Z"},
+		{input: `"2025-08-05T00:00:00-06:00"`, wantDate: "2025-08-05", wantUTC: "2025-08-05T06:00:00Z"},
+		{input: `"2025-08-05T00:00:00"`, wantDate: "2025-08-05", wantUTC: "2025-08-05T06:00:00Z"},
+		{input: `"2025-08-05T23:30:00.123"`, wantDate: "2025-08-05", wantUTC: "2025-08-06T05:30:00Z"},
+		{input: `"2025-01-15"`, wantDate: "2025-01-15", wantUTC: "2025-01-15T07:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var d DateTime
+			if err := json.Unmarshal([]byte(tt.input), &d); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if got := d.Date(); got != tt.wantDate {
+				t.Errorf("Date() = %s, want %s", got, tt.wantDate)
+			}
+			// Converting to UTC must not move the calendar date we report
+			if got := d.UTC().Format(time.RFC3339); got != tt.wantUTC {
+				t.Errorf("UTC() = %s, want %s", got, tt.wantUTC)
+			}
+		})
+	}
+}
+
+func TestDateTimeUnmarshalRejectsGarbage(t *testing.T) {
+	var d DateTime
+	if err := json.Unmarshal([]byte(`"next tuesday"`), &d); err == nil {
+		t.Errorf("Unmarshal() accepted %q", d)
+	}
+	if err := json.Unmarshal([]byte(`null`), &d); err != nil || !d.IsZero() {
+		t.Errorf("Unmarshal(null) = %v, %v, want zero value", d, err)
+	}
+}
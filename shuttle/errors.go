@@ -0,0 +1,118 @@
+package shuttle
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+// Sentinel errors describing why a request to the reservation API failed.
+// Use errors.Is against an error returned by APIClient to tell them apart.
+var (
+	ErrRateLimited    = errors.New("rate limited")
+	ErrBlocked        = errors.New("blocked or challenged")
+	ErrQueued         = errors.New("held in waiting room")
+	ErrServer         = errors.New("server error")
+	ErrDecode         = errors.New("unexpected response format")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrUnreachable    = errors.New("upstream unreachable")
+	ErrCircuitOpen    = errors.New("upstream unavailable")
+)
+
+// maxErrorBodyLen caps how much of a response body is kept on an UpstreamError
+const maxErrorBodyLen = 512
+
+// UpstreamError is returned by APIClient when the reservation API could not be
+// reached or answered with something other than a usable availability payload
+type UpstreamError struct {
+	Kind       error         // one of the sentinel errors above
+	StatusCode int           // HTTP status code, 0 if no response was received
+	Body       string        // response body, truncated to maxErrorBodyLen
+	Duration   time.Duration // time spent on the request
+	Err        error         // underlying error, if any
+}
+
+func (e *UpstreamError) Error() string {
+	msg := e.Kind.Error()
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s (status %d)", msg, e.StatusCode)
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	if e.Body != "" {
+		msg = fmt.Sprintf("%s, body: %s", msg, e.Body)
+	}
+	return fmt.Sprintf("%s after %s", msg, e.Duration.Round(time.Millisecond))
+}
+
+// Unwrap exposes both the error kind and the underlying cause to errors.Is and errors.As
+func (e *UpstreamError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// ErrorKind returns a short machine-readable name for the kind of err,
+// suitable for logs and JSON results
+func ErrorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrBlocked):
+		return "blocked"
+	case errors.Is(err, ErrQueued):
+		return "queued"
+	case errors.Is(err, ErrServer):
+		return "server_error"
+	case errors.Is(err, ErrDecode):
+		return "decode_error"
+	case errors.Is(err, ErrInvalidRequest):
+		return "invalid_request"
+	case errors.Is(err, ErrUnreachable):
+		return "unreachable"
+	case errors.Is(err, ErrCircuitOpen):
+		return "upstream_unavailable"
+	default:
+		return "error"
+	}
+}
+
+// kindForStatus maps a non-200 HTTP status code to an error kind
+func kindForStatus(statusCode int) error {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrBlocked
+	case statusCode >= 500:
+		return ErrServer
+	case statusCode >= 400:
+		return ErrInvalidRequest
+	default:
+		return ErrDecode
+	}
+}
+
+// truncateBody shortens body to at most maxErrorBodyLen bytes, without
+// splitting a multi-byte character
+func truncateBody(body []byte) string {
+	if len(body) <= maxErrorBodyLen {
+		return string(body)
+	}
+	cut := maxErrorBodyLen
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return string(body[:cut]) + "..."
+}
+
+// decodeError reports a 200 response whose body could not be decoded
+func decodeError(body []byte, duration time.Duration, err error) *UpstreamError {
+	return &UpstreamError{Kind: ErrDecode, StatusCode: http.StatusOK, Body: truncateBody(body), Duration: duration, Err: err}
+}
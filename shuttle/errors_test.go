@@ -0,0 +1,22 @@
+package shuttle
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateBodyKeepsRunesWhole(t *testing.T) {
+	// "é" is two bytes, so the limit falls in the middle of one
+	body := []byte("x" + strings.Repeat("é", maxErrorBodyLen))
+	got := truncateBody(body)
+	if !utf8.ValidString(got) {
+		t.Fatalf("truncateBody returned invalid UTF-8: %q", got[len(got)-8:])
+	}
+	if want := "x" + strings.Repeat("é", (maxErrorBodyLen-1)/2) + "..."; got != want {
+		t.Errorf("truncateBody = %d bytes, want %d", len(got), len(want))
+	}
+	if got := truncateBody([]byte("short")); got != "short" {
+		t.Errorf("truncateBody(short) = %q", got)
+	}
+}
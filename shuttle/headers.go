@@ -0,0 +1,193 @@
+package shuttle
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// HeaderProfile is the set of browser-identifying headers sent with every API request
+type HeaderProfile struct {
+	UserAgent   string
+	AppLanguage string
+	AppVersion  string
+	// Extra headers added verbatim, e.g. Sec-CH-UA hints
+	Extra map[string]string
+}
+
+// DefaultHeaderProfile mirrors a desktop Chrome session of the reservation web app
+func DefaultHeaderProfile() HeaderProfile {
+	return HeaderProfile{
+		UserAgent:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/136.0.0.0 Safari/537.36",
+		AppLanguage: "en-CA",
+		AppVersion:  "5.98.197",
+	}
+}
+
+// withDefaults fills any blank field from DefaultHeaderProfile
+func (p HeaderProfile) withDefaults() HeaderProfile {
+	def := DefaultHeaderProfile()
+	if p.UserAgent == "" {
+		p.UserAgent = def.UserAgent
+	}
+	if p.AppLanguage == "" {
+		p.AppLanguage = def.AppLanguage
+	}
+	if p.AppVersion == "" {
+		p.AppVersion = def.AppVersion
+	}
+	return p
+}
+
+func (p HeaderProfile) apply(req *http.Request) {
+	req.Header.Add("User-Agent", p.UserAgent)
+	req.Header.Add("App-Language", p.AppLanguage)
+	req.Header.Add("app-version", p.AppVersion)
+	for key, value := range p.Extra {
+		req.Header.Add(key, value)
+	}
+}
+
+// appVersionMaxAge is how long a discovered app version is trusted before it
+// is looked up again
+const appVersionMaxAge = 6 * time.Hour
+
+// maxScriptsScanned bounds how many front-end bundles are fetched per discovery
+const maxScriptsScanned = 6
+
+var (
+	scriptSrcPattern  = regexp.MustCompile(`(?i)<script[^>]+src=["']([^"']+\.js)["']`)
+	appVersionPattern = regexp.MustCompile(`(?i)["']?app-?version["']?\s*[:=]\s*["'](\d+\.\d+\.\d+)["']`)
+	versionPattern    = regexp.MustCompile(`\bversion\s*:\s*["'](\d+\.\d+\.\d+)["']`)
+)
+
+// DiscoverAppVersion finds the version of the reservation web app currently
+// deployed by scanning its landing page and front-end bundles
+func (c *APIClient) DiscoverAppVersion() (string, error) {
+	page, err := c.fetchAsset(reservationBaseURL + "/")
+	if err != nil {
+		return "", err
+	}
+	if version := findAppVersion(page); version != "" {
+		return version, nil
+	}
+
+	base, _ := url.Parse(reservationBaseURL + "/")
+	scripts := scriptSrcPattern.FindAllStringSubmatch(page, -1)
+	// The app version is baked into the main bundle, so scan it first
+	for i, m := range scripts {
+		if strings.Contains(m[1], "main") {
+			scripts[0], scripts[i] = scripts[i], scripts[0]
+			break
+		}
+	}
+	for i, m := range scripts {
+		if i >= maxScriptsScanned {
+			break
+		}
+		src, err := base.Parse(m[1])
+		if err != nil {
+			continue
+		}
+		script, err := c.fetchAsset(src.String())
+		if err != nil {
+			log.Printf("Error fetching %s during app version discovery: %v", src, err)
+			continue
+		}
+		if version := findAppVersion(script); version != "" {
+			return version, nil
+		}
+	}
+
+	return "", fmt.Errorf("app version not found in %d front-end assets", len(scripts)+1)
+}
+
+func findAppVersion(content string) string {
+	if m := appVersionPattern.FindStringSubmatch(content); m != nil {
+		return m[1]
+	}
+	if m := versionPattern.FindStringSubmatch(content); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func (c *APIClient) fetchAsset(assetURL string) (string, error) {
+	req, err := http.NewRequest("GET", assetURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Add("User-Agent", c.headers.withDefaults().UserAgent)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return string(body), nil
+}
+
+// headerProfile returns the profile to send, refreshing the app version first
+// when discovery is enabled and the known version is missing, stale or old
+func (c *APIClient) headerProfile() HeaderProfile {
+	profile := c.headers.withDefaults()
+	if !c.discoverVersion {
+		return profile
+	}
+
+	// Discovery makes several requests, so it runs without the lock; requests
+	// made meanwhile use the version known so far. Setting versionCheckedAt
+	// up front also means a front-end that can't be reached is retried no
+	// sooner than appVersionMaxAge rather than on every request.
+	c.versionMu.Lock()
+	stale := c.versionCheckedAt.IsZero() || time.Since(c.versionCheckedAt) > appVersionMaxAge
+	if stale {
+		c.versionCheckedAt = time.Now()
+	}
+	known := c.appVersion
+	c.versionMu.Unlock()
+	if known != "" {
+		profile.AppVersion = known
+	}
+	if !stale {
+		return profile
+	}
+
+	version, err := c.DiscoverAppVersion()
+	if err != nil {
+		log.Printf("Error discovering app version, using %s: %v", profile.AppVersion, err)
+		return profile
+	}
+	if version != known {
+		log.Printf("Discovered reservation app version %s", version)
+	}
+	c.versionMu.Lock()
+	c.appVersion = version
+	c.versionMu.Unlock()
+	profile.AppVersion = version
+	return profile
+}
+
+// markAppVersionStale forces a new discovery before the next request, used
+// when the API rejects us in a way an outdated version header would explain
+func (c *APIClient) markAppVersionStale() {
+	if !c.discoverVersion {
+		return
+	}
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+	c.versionCheckedAt = time.Time{}
+}
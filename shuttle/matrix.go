@@ -0,0 +1,108 @@
+package shuttle
+
+import (
+	"sort"
+	"time"
+)
+
+// AvailabilityMatrix holds the availability of every resource by date
+// (formatted 2006-01-02) and resource ID
+type AvailabilityMatrix map[string]map[int64]AvailabilityResult
+
+// NewAvailabilityMatrix indexes the records of an API response
+func NewAvailabilityMatrix(availabilities []ResourceAvailability) AvailabilityMatrix {
+	m := make(AvailabilityMatrix)
+	m.add(availabilities)
+	return m
+}
+
+func (m AvailabilityMatrix) add(availabilities []ResourceAvailability) {
+	for _, avail := range availabilities {
+		date := avail.Range.Start.Date()
+		if _, exists := m[date]; !exists {
+			m[date] = make(map[int64]AvailabilityResult)
+		}
+		m[date][int64(avail.ResourceID)] = avail.AvailabilityResult
+	}
+}
+
+// AvailableDates returns the sorted dates on which every resource in
+// resourceIDs has reservable quota
+func (m AvailabilityMatrix) AvailableDates(resourceIDs []int64) []string {
+	var dates []string
+	for date, resources := range m {
+		allResourcesAvailable := true
+		for _, resourceID := range resourceIDs {
+			result, exists := resources[resourceID]
+			if !exists || result.RemainingReservableQuota <= 0 {
+				allResourcesAvailable = false
+				break
+			}
+		}
+		if allResourcesAvailable {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// LocationAvailability is the merged answer for all dates watched at a Location
+type LocationAvailability struct {
+	Location Location
+	// Matrix only contains the dates listed in Location.Dates
+	Matrix AvailabilityMatrix
+	// Cached is true when every window was served from the response cache
+	Cached bool
+	// FetchedAt is when the oldest window was fetched from upstream
+	FetchedAt time.Time
+}
+
+// AvailableDates returns the watched dates on which every resource of the
+// location has reservable quota
+func (la *LocationAvailability) AvailableDates() []string {
+	return la.Matrix.AvailableDates(la.Location.ResourceIDs)
+}
+
+// Age returns how old the oldest data in the answer is
+func (la *LocationAvailability) Age() time.Duration {
+	return time.Since(la.FetchedAt)
+}
+
+// CheckLocation fetches availability for every date watched at loc, split into
+// as few contiguous windows as possible, and merges the answers
+func (c *APIClient) CheckLocation(loc Location, forceRefresh bool) (*LocationAvailability, error) {
+	windows, err := PlanDateWindows(loc.Dates, c.maxWindowDays)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(AvailabilityMatrix)
+	la := &LocationAvailability{Location: loc, Cached: len(windows) > 0}
+	for _, window := range windows {
+		snapshot, err := c.FetchAvailability(AvailabilityQuery{
+			LocationID:      loc.LocationID,
+			BookingCategory: loc.BookingCategory,
+			StartDate:       window.Start,
+			EndDate:         window.End,
+			ResourceIDs:     loc.ResourceIDs,
+			ForceRefresh:    forceRefresh,
+		})
+		if err != nil {
+			return nil, err
+		}
+		merged.add(snapshot.Availabilities)
+		la.Cached = la.Cached && snapshot.Cached
+		if la.FetchedAt.IsZero() || snapshot.FetchedAt.Before(la.FetchedAt) {
+			la.FetchedAt = snapshot.FetchedAt
+		}
+	}
+
+	la.Matrix = make(AvailabilityMatrix)
+	for _, date := range loc.Dates {
+		if resources, ok := merged[date]; ok {
+			la.Matrix[date] = resources
+		}
+	}
+	return la, nil
+}
@@ -0,0 +1,57 @@
+package shuttle
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// DateWindow is an inclusive range of dates fetched with a single API request
+type DateWindow struct {
+	Start string
+	End   string
+}
+
+// PlanDateWindows groups dates into the fewest contiguous windows, so that
+// watching June 1 and August 30 costs two one-day requests rather than three
+// months of daily activity. Windows never span more than maxSpanDays days;
+// zero or less means no limit. Duplicate dates are ignored.
+func PlanDateWindows(dates []string, maxSpanDays int) ([]DateWindow, error) {
+	days := make([]time.Time, 0, len(dates))
+	seen := make(map[string]bool)
+	for _, date := range dates {
+		if seen[date] {
+			continue
+		}
+		seen[date] = true
+		day, err := time.Parse(dateLayout, date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q: %w", date, err)
+		}
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	var windows []DateWindow
+	for i := 0; i < len(days); {
+		start := days[i]
+		end := start
+		i++
+		for i < len(days) && days[i].Equal(end.AddDate(0, 0, 1)) {
+			if maxSpanDays > 0 && spanDays(start, days[i]) > maxSpanDays {
+				break
+			}
+			end = days[i]
+			i++
+		}
+		windows = append(windows, DateWindow{Start: start.Format(dateLayout), End: end.Format(dateLayout)})
+	}
+	return windows, nil
+}
+
+// spanDays counts the days from start to end, both included
+func spanDays(start, end time.Time) int {
+	return int(end.Sub(start).Hours()/24) + 1
+}
@@ -0,0 +1,53 @@
+package shuttle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanDateWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		dates   []string
+		maxSpan int
+		want    []DateWindow
+	}{
+		{
+			name:  "far apart dates",
+			dates: []string{"2025-08-30", "2025-06-01"},
+			want:  []DateWindow{{"2025-06-01", "2025-06-01"}, {"2025-08-30", "2025-08-30"}},
+		},
+		{
+			name:  "contiguous run across a month boundary",
+			dates: []string{"2025-07-31", "2025-08-01", "2025-08-02", "2025-08-05"},
+			want:  []DateWindow{{"2025-07-31", "2025-08-02"}, {"2025-08-05", "2025-08-05"}},
+		},
+		{
+			name:    "run split by maximum span",
+			dates:   []string{"2025-08-01", "2025-08-02", "2025-08-03", "2025-08-04", "2025-08-05"},
+			maxSpan: 2,
+			want:    []DateWindow{{"2025-08-01", "2025-08-02"}, {"2025-08-03", "2025-08-04"}, {"2025-08-05", "2025-08-05"}},
+		},
+		{
+			name:  "duplicates",
+			dates: []string{"2025-08-05", "2025-08-05", "2025-08-06"},
+			want:  []DateWindow{{"2025-08-05", "2025-08-06"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PlanDateWindows(tt.dates, tt.maxSpan)
+			if err != nil {
+				t.Fatalf("PlanDateWindows() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PlanDateWindows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := PlanDateWindows([]string{"08/05/2025"}, 0); err == nil {
+		t.Errorf("PlanDateWindows() accepted a malformed date")
+	}
+}
@@ -0,0 +1,244 @@
+package shuttle
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyStrategy decides which egress of a proxy pool serves the next request
+type ProxyStrategy string
+
+const (
+	// ProxyRoundRobin spreads requests evenly over all healthy egresses
+	ProxyRoundRobin ProxyStrategy = "round-robin"
+	// ProxyFailover sticks to the first healthy egress in configured order
+	ProxyFailover ProxyStrategy = "failover"
+)
+
+const (
+	// egressFailureLimit consecutive failures mark an egress unhealthy
+	egressFailureLimit = 3
+	// egressCooldown is how long an unhealthy egress is skipped
+	egressCooldown = 5 * time.Minute
+)
+
+// ProxyStats is a point-in-time view of one egress of the proxy pool
+type ProxyStats struct {
+	Proxy     string // proxy URL with credentials redacted, or "direct"
+	Requests  uint64
+	Failures  uint64
+	Healthy   bool
+	LastError string
+}
+
+// ParseProxies parses a comma-separated list of proxy URLs. Supported schemes
+// are http, https and socks5; the word "direct" adds a connection without proxy.
+func ParseProxies(list string) ([]*url.URL, error) {
+	var proxies []*url.URL
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "direct" {
+			proxies = append(proxies, nil)
+			continue
+		}
+		u, err := url.Parse(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", entry, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q in %s", u.Scheme, u.Redacted())
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("proxy %s has no host", u.Redacted())
+		}
+		proxies = append(proxies, u)
+	}
+	return proxies, nil
+}
+
+// ParseProxyStrategy validates a strategy name; an empty name means round-robin
+func ParseProxyStrategy(name string) (ProxyStrategy, error) {
+	switch ProxyStrategy(name) {
+	case "", ProxyRoundRobin:
+		return ProxyRoundRobin, nil
+	case ProxyFailover:
+		return ProxyFailover, nil
+	default:
+		return "", fmt.Errorf("unknown proxy strategy %q", name)
+	}
+}
+
+// WithProxies sends requests through the given proxies, chosen per request
+// by strategy. A nil entry stands for a direct connection.
+func WithProxies(proxies []*url.URL, strategy ProxyStrategy) Option {
+	return func(c *APIClient) {
+		c.proxies = proxies
+		c.proxyStrategy = strategy
+	}
+}
+
+// ProxyStats reports per-egress counters; it is empty when no proxies are configured
+func (c *APIClient) ProxyStats() []ProxyStats {
+	if c.pool == nil {
+		return nil
+	}
+	return c.pool.stats()
+}
+
+// egress is one way out to the reservation site, with its own connection pool
+type egress struct {
+	label  string
+	client *http.Client
+
+	requests       uint64
+	failures       uint64
+	consecutive    int
+	unhealthyUntil time.Time
+	lastErr        string
+}
+
+type proxyPool struct {
+	strategy ProxyStrategy
+
+	mu       sync.Mutex
+	egresses []*egress
+	next     int
+}
+
+// newProxyPool builds one HTTP client per proxy, sharing the session cookie jar
+// and timeout of base
+func newProxyPool(proxies []*url.URL, strategy ProxyStrategy, base *http.Client) *proxyPool {
+	pool := &proxyPool{strategy: strategy}
+	for _, proxy := range proxies {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		label := "direct"
+		transport.Proxy = nil
+		if proxy != nil {
+			label = proxy.Redacted()
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+		pool.egresses = append(pool.egresses, &egress{
+			label: label,
+			client: &http.Client{
+				Transport: transport,
+				Jar:       base.Jar,
+				Timeout:   base.Timeout,
+			},
+		})
+	}
+	return pool
+}
+
+func (p *proxyPool) size() int {
+	return len(p.egresses)
+}
+
+// pick returns the egress for the next request, skipping those in tried.
+// Unhealthy egresses are skipped too unless nothing else is left, in which
+// case the rotation continues anyway.
+func (p *proxyPool) pick(tried map[*egress]bool) *egress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	start := p.next
+	if p.strategy == ProxyFailover {
+		start = 0
+	}
+	var fallback *egress
+	for i := 0; i < len(p.egresses); i++ {
+		idx := (start + i) % len(p.egresses)
+		e := p.egresses[idx]
+		if tried[e] {
+			continue
+		}
+		if now.After(e.unhealthyUntil) {
+			p.next = (idx + 1) % len(p.egresses)
+			return e
+		}
+		if fallback == nil {
+			fallback = e
+		}
+	}
+	p.next = (p.next + 1) % len(p.egresses)
+	return fallback
+}
+
+// preferred returns the egress pick would choose, without advancing the rotation
+func (p *proxyPool) preferred() *egress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for i := 0; i < len(p.egresses); i++ {
+		idx := i
+		if p.strategy == ProxyRoundRobin {
+			idx = (p.next + i) % len(p.egresses)
+		}
+		if now.After(p.egresses[idx].unhealthyUntil) {
+			return p.egresses[idx]
+		}
+	}
+	return p.egresses[0]
+}
+
+// record updates the health of e after a request through it
+func (p *proxyPool) record(e *egress, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.requests++
+	if err == nil || !isEgressFailure(err) {
+		e.consecutive = 0
+		return
+	}
+
+	e.failures++
+	e.consecutive++
+	e.lastErr = err.Error()
+	if e.consecutive >= egressFailureLimit {
+		e.unhealthyUntil = time.Now().Add(egressCooldown)
+		log.Printf("Egress %s marked unhealthy for %s after %d consecutive failures (%d/%d requests failed)",
+			e.label, egressCooldown, e.consecutive, e.failures, e.requests)
+	} else {
+		log.Printf("Request via %s failed (%s), %d/%d requests failed", e.label, ErrorKind(err), e.failures, e.requests)
+	}
+}
+
+func (p *proxyPool) stats() []ProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]ProxyStats, 0, len(p.egresses))
+	for _, e := range p.egresses {
+		stats = append(stats, ProxyStats{
+			Proxy:     e.label,
+			Requests:  e.requests,
+			Failures:  e.failures,
+			Healthy:   now.After(e.unhealthyUntil),
+			LastError: e.lastErr,
+		})
+	}
+	return stats
+}
+
+// isEgressFailure reports errors that another egress might not run into:
+// connection problems and the site pushing back on our address
+func isEgressFailure(err error) bool {
+	return errors.Is(err, ErrUnreachable) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrBlocked) ||
+		errors.Is(err, ErrQueued)
+}
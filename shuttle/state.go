@@ -0,0 +1,105 @@
+package shuttle
+
+import "sort"
+
+// SlotState classifies the availability of a resource, or of a whole watch, on one date
+type SlotState string
+
+const (
+	// StateAvailable means seats can be booked right now
+	StateAvailable SlotState = "available"
+	// StateSoldOut means the day is on sale but every seat is taken; only
+	// cancellations will free one up
+	StateSoldOut SlotState = "sold_out"
+	// StateNotReleased means seats exist but are held back until a later
+	// release, such as the 48-hour advance release
+	StateNotReleased SlotState = "not_yet_released"
+	// StateClosed means the service doesn't run or was closed for sale that day
+	StateClosed SlotState = "closed"
+	// StateUnknown means the API gave no usable answer for the date
+	StateUnknown SlotState = "unknown"
+)
+
+// resultCodeOK is the resultCode of a day that is open for sale
+const resultCodeOK = 0
+
+// State derives the slot state from the quota fields of a result:
+// reservable seats mean available; total quota beyond the reservable quota is
+// held back for a later release; closed quota without any remaining seats
+// means the day was closed; an open day without seats is sold out. Any other
+// result code is reported as unknown rather than guessed at.
+func (r AvailabilityResult) State() SlotState {
+	switch {
+	case r.RemainingReservableQuota > 0:
+		return StateAvailable
+	case r.RemainingTotalQuota > r.RemainingReservableQuota:
+		return StateNotReleased
+	case r.ClosedQuota > 0:
+		return StateClosed
+	case r.ResultCode == resultCodeOK:
+		return StateSoldOut
+	default:
+		return StateUnknown
+	}
+}
+
+// ResourceStatus is the state of one resource (departure) on a date
+type ResourceStatus struct {
+	ResourceID int64     `json:"resourceId"`
+	State      SlotState `json:"state"`
+	Seats      int       `json:"seats"`
+}
+
+// DateStatus is the state of a watch on one date, with its resources
+type DateStatus struct {
+	Date      string           `json:"date"`
+	State     SlotState        `json:"state"`
+	Resources []ResourceStatus `json:"resources"`
+}
+
+// combinedStatePriority orders the states reported for a date when not every
+// resource is available: the most hopeful explanation wins
+var combinedStatePriority = []SlotState{StateNotReleased, StateSoldOut, StateClosed, StateUnknown}
+
+// Statuses classifies every date in dates for the given resources. A date is
+// available only when every resource is, matching AvailableDates.
+func (m AvailabilityMatrix) Statuses(dates []string, resourceIDs []int64) []DateStatus {
+	sorted := append([]string(nil), dates...)
+	sort.Strings(sorted)
+
+	statuses := make([]DateStatus, 0, len(sorted))
+	for i, date := range sorted {
+		if i > 0 && sorted[i-1] == date {
+			continue
+		}
+		status := DateStatus{Date: date}
+		seen := make(map[SlotState]bool)
+		for _, resourceID := range resourceIDs {
+			rs := ResourceStatus{ResourceID: resourceID, State: StateUnknown}
+			if result, ok := m[date][resourceID]; ok {
+				rs.State = result.State()
+				rs.Seats = int(result.RemainingReservableQuota)
+			}
+			seen[rs.State] = true
+			status.Resources = append(status.Resources, rs)
+		}
+
+		status.State = StateAvailable
+		for _, state := range combinedStatePriority {
+			if seen[state] {
+				status.State = state
+				break
+			}
+		}
+		if len(resourceIDs) == 0 {
+			status.State = StateUnknown
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Statuses classifies every watched date of the location
+func (la *LocationAvailability) Statuses() []DateStatus {
+	return la.Matrix.Statuses(la.Location.Dates, la.Location.ResourceIDs)
+}
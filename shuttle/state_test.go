@@ -0,0 +1,60 @@
+package shuttle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAvailabilityResultState(t *testing.T) {
+	tests := []struct {
+		name   string
+		result AvailabilityResult
+		want   SlotState
+	}{
+		{name: "seats left", result: AvailabilityResult{RemainingReservableQuota: 2, RemainingTotalQuota: 5}, want: StateAvailable},
+		{name: "held for later release", result: AvailabilityResult{RemainingReservableQuota: 0, RemainingTotalQuota: 12}, want: StateNotReleased},
+		{name: "closed", result: AvailabilityResult{ClosedQuota: 40}, want: StateClosed},
+		{name: "sold out", result: AvailabilityResult{ResultCode: 0}, want: StateSoldOut},
+		{name: "unexpected result code", result: AvailabilityResult{ResultCode: 7}, want: StateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.State(); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvailabilityMatrixStatuses(t *testing.T) {
+	m := AvailabilityMatrix{
+		"2025-08-05": {
+			1: {RemainingReservableQuota: 3, RemainingTotalQuota: 3},
+			2: {RemainingReservableQuota: 1, RemainingTotalQuota: 1},
+		},
+		"2025-08-06": {
+			1: {RemainingReservableQuota: 0, RemainingTotalQuota: 0},
+			2: {RemainingReservableQuota: 0, RemainingTotalQuota: 10},
+		},
+	}
+
+	got := m.Statuses([]string{"2025-08-07", "2025-08-06", "2025-08-05"}, []int64{1, 2})
+	want := []DateStatus{
+		{Date: "2025-08-05", State: StateAvailable, Resources: []ResourceStatus{
+			{ResourceID: 1, State: StateAvailable, Seats: 3},
+			{ResourceID: 2, State: StateAvailable, Seats: 1},
+		}},
+		{Date: "2025-08-06", State: StateNotReleased, Resources: []ResourceStatus{
+			{ResourceID: 1, State: StateSoldOut},
+			{ResourceID: 2, State: StateNotReleased},
+		}},
+		{Date: "2025-08-07", State: StateUnknown, Resources: []ResourceStatus{
+			{ResourceID: 1, State: StateUnknown},
+			{ResourceID: 2, State: StateUnknown},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Statuses() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+
+	var missing map[string]int
+	require.NoError(t, Load(path, &missing))
+	assert.Nil(t, missing)
+
+	require.NoError(t, Save(path, map[string]int{"a": 1}))
+	require.NoError(t, Save(path, map[string]int{"b": 2}))
+
+	var got map[string]int
+	require.NoError(t, Load(path, &got))
+	assert.Equal(t, map[string]int{"b": 2}, got)
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+	var v map[string]int
+	assert.Error(t, Load(path, &v))
+}
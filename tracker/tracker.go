@@ -0,0 +1,189 @@
+// Package tracker remembers which departures of each watch were available at
+// the last check, so notifications go out on transitions rather than on every
+// check that finds seats
+package tracker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/notification"
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/BohdanMelnyk/bus-shulter-checker/store"
+)
+
+// Config controls when an observation is worth a notification
+type Config struct {
+	// SeatThreshold is the fewest seats a departure needs to count as open, e.g.
+	// the size of your group; a departure crossing it counts as newly open
+	SeatThreshold int
+	// Cooldown is the least time between two notifications for a watch
+	Cooldown time.Duration
+	// ReminderInterval re-sends a "still open" notification when slots stay
+	// open this long without news; zero disables reminders
+	ReminderInterval time.Duration
+}
+
+// Slot is one departure on one date that is open
+type Slot struct {
+	Date       string    `json:"date"`
+	ResourceID int64     `json:"resourceId"`
+	Seats      int       `json:"seats"`
+	OpenSince  time.Time `json:"openSince"`
+	NotifiedAt time.Time `json:"notifiedAt,omitempty"`
+	// Uncounted is set on a reported slot whose departure is still available
+	// but no longer counts as open, e.g. below SeatThreshold or on a date that
+	// is only partly available. It is kept so that it can be reported gone.
+	Uncounted bool `json:"uncounted,omitempty"`
+}
+
+func (s *Slot) key() string {
+	return fmt.Sprintf("%s/%d", s.Date, s.ResourceID)
+}
+
+type watchState struct {
+	Open           map[string]*Slot `json:"open"`
+	LastNotifiedAt time.Time        `json:"lastNotifiedAt,omitempty"`
+}
+
+// Decision is the outcome of an observation
+type Decision struct {
+	// Notify says whether the observation should be sent
+	Notify bool
+	// Reminder is set when nothing new opened but a reminder is due
+	Reminder bool
+	// New lists slots that opened since the last notification
+	New []Slot
+	// Gone lists reported slots whose departures are no longer available
+	Gone []Slot
+}
+
+// Tracker keeps per-watch state in a JSON file
+type Tracker struct {
+	cfg  Config
+	path string
+
+	mu      sync.Mutex
+	watches map[string]*watchState
+}
+
+// Open loads the tracker state from path; an empty path keeps it in memory only
+func Open(path string, cfg Config) (*Tracker, error) {
+	if cfg.SeatThreshold < 1 {
+		cfg.SeatThreshold = 1
+	}
+	t := &Tracker{cfg: cfg, path: path, watches: make(map[string]*watchState)}
+	if path != "" {
+		if err := store.Load(path, &t.watches); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Observe records the departures of event that are open now and decides
+// whether the event should be sent. Only departures on available dates count,
+// matching Event.AvailableDates, so every notified slot shows up in the
+// message. Reported slots that stop counting are kept until their departure
+// is no longer available, and then reported as gone; they don't count as new
+// if they count again in the meantime. Other departures that closed are
+// forgotten, so they count as new if they open again.
+func (t *Tracker) Observe(event notification.Event, now time.Time) (Decision, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w := t.watch(event.WatchID)
+	open := make(map[string]*Slot)
+	available := make(map[string]bool)
+	for _, date := range event.Dates {
+		for _, dep := range date.Departures {
+			if dep.State == shuttle.StateAvailable {
+				slot := Slot{Date: date.Date, ResourceID: dep.ResourceID}
+				available[slot.key()] = true
+			}
+		}
+		if date.State != shuttle.StateAvailable {
+			continue
+		}
+		for _, dep := range date.Departures {
+			if dep.State != shuttle.StateAvailable || dep.Seats < t.cfg.SeatThreshold {
+				continue
+			}
+			slot := &Slot{Date: date.Date, ResourceID: dep.ResourceID, Seats: dep.Seats, OpenSince: now}
+			if prev, ok := w.Open[slot.key()]; ok {
+				slot.OpenSince = prev.OpenSince
+				slot.NotifiedAt = prev.NotifiedAt
+			}
+			open[slot.key()] = slot
+		}
+	}
+
+	var d Decision
+	state := make(map[string]*Slot, len(open))
+	for key, slot := range open {
+		state[key] = slot
+	}
+	for key, slot := range w.Open {
+		if _, ok := open[key]; ok || slot.NotifiedAt.IsZero() {
+			continue
+		}
+		if available[key] {
+			slot.Uncounted = true
+			state[key] = slot
+		} else {
+			d.Gone = append(d.Gone, *slot)
+		}
+	}
+	sort.Slice(d.Gone, func(i, j int) bool { return d.Gone[i].key() < d.Gone[j].key() })
+	w.Open = state
+
+	for _, slot := range open {
+		if slot.NotifiedAt.IsZero() {
+			d.New = append(d.New, *slot)
+		}
+	}
+	sort.Slice(d.New, func(i, j int) bool { return d.New[i].key() < d.New[j].key() })
+
+	sinceLast := now.Sub(w.LastNotifiedAt)
+	switch {
+	case len(d.New) > 0:
+		d.Notify = w.LastNotifiedAt.IsZero() || sinceLast >= t.cfg.Cooldown
+	case len(open) > 0 && t.cfg.ReminderInterval > 0:
+		d.Notify = sinceLast >= t.cfg.ReminderInterval
+		d.Reminder = d.Notify
+	}
+	return d, t.save()
+}
+
+// MarkNotified records that the open slots of a watch were sent at now
+func (t *Tracker) MarkNotified(watchID string, now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w := t.watch(watchID)
+	for _, slot := range w.Open {
+		if slot.NotifiedAt.IsZero() {
+			slot.NotifiedAt = now
+		}
+	}
+	w.LastNotifiedAt = now
+	return t.save()
+}
+
+func (t *Tracker) watch(id string) *watchState {
+	w, ok := t.watches[id]
+	if !ok {
+		w = &watchState{Open: make(map[string]*Slot)}
+		t.watches[id] = w
+	}
+	return w
+}
+
+func (t *Tracker) save() error {
+	if t.path == "" {
+		return nil
+	}
+	return store.Save(t.path, t.watches)
+}
@@ -0,0 +1,162 @@
+package tracker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BohdanMelnyk/bus-shulter-checker/notification"
+	"github.com/BohdanMelnyk/bus-shulter-checker/shuttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// event builds an observation of watch "w" with the given seats per departure
+// on 2025-08-05. The date is available when any departure is; tests of partly
+// available dates set its state themselves.
+func event(seats ...int) notification.Event {
+	date := notification.DateAvailability{Date: "2025-08-05", State: shuttle.StateSoldOut}
+	for i, n := range seats {
+		state := shuttle.StateSoldOut
+		if n > 0 {
+			state = shuttle.StateAvailable
+			date.State = shuttle.StateAvailable
+		}
+		date.Departures = append(date.Departures, notification.Departure{ResourceID: int64(i + 1), State: state, Seats: n})
+	}
+	return notification.Event{Kind: notification.KindOpened, WatchID: "w", Dates: []notification.DateAvailability{date}}
+}
+
+func TestTracker_NotifiesOnTransitions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watches.json")
+	tr, err := Open(path, Config{})
+	require.NoError(t, err)
+	now := time.Date(2025, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	d, err := tr.Observe(event(2, 0), now)
+	require.NoError(t, err)
+	assert.True(t, d.Notify)
+	require.Len(t, d.New, 1)
+	assert.Equal(t, int64(1), d.New[0].ResourceID)
+	require.NoError(t, tr.MarkNotified("w", now))
+
+	// Same slot still open: nothing to say
+	d, _ = tr.Observe(event(1, 0), now.Add(30*time.Minute))
+	assert.False(t, d.Notify)
+
+	// State survives a restart; a second departure opening is news
+	tr, err = Open(path, Config{})
+	require.NoError(t, err)
+	d, _ = tr.Observe(event(1, 3), now.Add(time.Hour))
+	assert.True(t, d.Notify)
+	require.Len(t, d.New, 1)
+	assert.Equal(t, int64(2), d.New[0].ResourceID)
+	require.NoError(t, tr.MarkNotified("w", now.Add(time.Hour)))
+
+	// A slot that closes and reopens counts as new again
+	d, _ = tr.Observe(event(0, 3), now.Add(90*time.Minute))
+	assert.False(t, d.Notify)
+	require.Len(t, d.Gone, 1)
+	assert.Equal(t, int64(1), d.Gone[0].ResourceID)
+	assert.Equal(t, now, d.Gone[0].OpenSince)
+	d, _ = tr.Observe(event(1, 3), now.Add(2*time.Hour))
+	assert.True(t, d.Notify)
+}
+
+func TestTracker_IgnoresPartlyAvailableDates(t *testing.T) {
+	tr, err := Open("", Config{})
+	require.NoError(t, err)
+
+	// One departure has seats and the others are sold out, so the date as a
+	// whole isn't available and a message would have no dates to show
+	partial := event(2, 0, 0, 0)
+	partial.Dates[0].State = shuttle.StateSoldOut
+	require.Empty(t, partial.AvailableDates())
+	d, err := tr.Observe(partial, time.Now())
+	require.NoError(t, err)
+	assert.False(t, d.Notify)
+	assert.Empty(t, d.New)
+
+	d, _ = tr.Observe(event(2, 1, 3, 1), time.Now())
+	assert.True(t, d.Notify)
+	assert.Len(t, d.New, 4)
+}
+
+func TestTracker_SeatThreshold(t *testing.T) {
+	tr, err := Open("", Config{SeatThreshold: 4})
+	require.NoError(t, err)
+	now := time.Now()
+
+	d, _ := tr.Observe(event(2), now)
+	assert.False(t, d.Notify)
+	d, _ = tr.Observe(event(5), now.Add(time.Minute))
+	assert.True(t, d.Notify)
+	assert.Equal(t, 5, d.New[0].Seats)
+	require.NoError(t, tr.MarkNotified("w", now.Add(time.Minute)))
+
+	// A slot that drops below the threshold is kept while seats are left,
+	// without counting as open or new
+	d, _ = tr.Observe(event(2), now.Add(2*time.Minute))
+	assert.False(t, d.Notify)
+	assert.Empty(t, d.Gone)
+	d, _ = tr.Observe(event(5), now.Add(3*time.Minute))
+	assert.Empty(t, d.New)
+	d, _ = tr.Observe(event(1), now.Add(4*time.Minute))
+	assert.Empty(t, d.Gone)
+
+	// and reported gone once they run out
+	d, _ = tr.Observe(event(0), now.Add(5*time.Minute))
+	assert.False(t, d.Notify)
+	require.Len(t, d.Gone, 1)
+	assert.Equal(t, int64(1), d.Gone[0].ResourceID)
+	assert.Equal(t, now.Add(time.Minute), d.Gone[0].OpenSince)
+	d, _ = tr.Observe(event(0), now.Add(6*time.Minute))
+	assert.Empty(t, d.Gone)
+}
+
+func TestTracker_DeparturesSellingOutOneAtATime(t *testing.T) {
+	tr, err := Open("", Config{})
+	require.NoError(t, err)
+	now := time.Now()
+
+	d, _ := tr.Observe(event(2, 2), now)
+	require.Len(t, d.New, 2)
+	require.NoError(t, tr.MarkNotified("w", now))
+
+	// The first departure selling out leaves the date only partly available
+	partial := event(0, 2)
+	partial.Dates[0].State = shuttle.StateSoldOut
+	d, _ = tr.Observe(partial, now.Add(time.Minute))
+	require.Len(t, d.Gone, 1)
+	assert.Equal(t, int64(1), d.Gone[0].ResourceID)
+
+	// The second one is still followed up on when it sells out too
+	d, _ = tr.Observe(event(0, 0), now.Add(2*time.Minute))
+	require.Len(t, d.Gone, 1)
+	assert.Equal(t, int64(2), d.Gone[0].ResourceID)
+}
+
+func TestTracker_CooldownAndReminder(t *testing.T) {
+	tr, err := Open("", Config{Cooldown: time.Hour, ReminderInterval: 6 * time.Hour})
+	require.NoError(t, err)
+	now := time.Date(2025, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	d, _ := tr.Observe(event(2, 0), now)
+	require.True(t, d.Notify)
+	require.NoError(t, tr.MarkNotified("w", now))
+
+	// New slot during the cooldown waits until it's over
+	d, _ = tr.Observe(event(2, 2), now.Add(10*time.Minute))
+	assert.False(t, d.Notify)
+	d, _ = tr.Observe(event(2, 2), now.Add(time.Hour))
+	assert.True(t, d.Notify)
+	assert.False(t, d.Reminder)
+	require.NoError(t, tr.MarkNotified("w", now.Add(time.Hour)))
+
+	d, _ = tr.Observe(event(2, 2), now.Add(5*time.Hour))
+	assert.False(t, d.Notify)
+	d, _ = tr.Observe(event(2, 2), now.Add(7*time.Hour))
+	assert.True(t, d.Notify)
+	assert.True(t, d.Reminder)
+	assert.Empty(t, d.New)
+}